@@ -0,0 +1,317 @@
+package inmemdb
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	bitmapWords    = 1 << 16 / 64
+	arrayMaxValues = 4096 // array containers are converted to bitmaps above this size
+)
+
+// Bitmap is a compressed set of uint32 in roaring bitmap style: values are split by high 16 bits
+// into containers, that are sorted arrays of low 16 bits for sparse and bitmaps for dense parts.
+// And, Or and AndNot don't modify operands.
+type Bitmap struct {
+	keys []uint16
+	cs   []*container
+}
+
+type container struct {
+	arr  []uint16 // sorted values, if bits is nil
+	bits []uint64
+	n    int
+}
+
+func NewBitmap(vals ...uint32) *Bitmap {
+	b := &Bitmap{}
+	for _, v := range vals {
+		b.Add(v)
+	}
+	return b
+}
+
+func (b *Bitmap) find(key uint16) (int, bool) {
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= key })
+	return i, i < len(b.keys) && b.keys[i] == key
+}
+
+func (b *Bitmap) Add(x uint32) {
+	key := uint16(x >> 16)
+	i, ok := b.find(key)
+	if !ok {
+		b.keys = insertItem(b.keys, i, key)
+		b.cs = insertItem(b.cs, i, &container{})
+	}
+	b.cs[i].add(uint16(x))
+}
+
+func (b *Bitmap) Remove(x uint32) {
+	i, ok := b.find(uint16(x >> 16))
+	if !ok {
+		return
+	}
+	if c := b.cs[i]; c.remove(uint16(x)) && c.n == 0 {
+		b.keys = removeItem(b.keys, i)
+		b.cs = removeItem(b.cs, i)
+	}
+}
+
+func (b *Bitmap) Contains(x uint32) bool {
+	i, ok := b.find(uint16(x >> 16))
+	return ok && b.cs[i].contains(uint16(x))
+}
+
+func (b *Bitmap) Cardinality() int {
+	n := 0
+	for _, c := range b.cs {
+		n += c.n
+	}
+	return n
+}
+
+func (b *Bitmap) IsEmpty() bool {
+	return len(b.cs) == 0
+}
+
+func (b *Bitmap) Clone() *Bitmap {
+	res := &Bitmap{
+		keys: append([]uint16(nil), b.keys...),
+		cs:   make([]*container, len(b.cs)),
+	}
+	for i, c := range b.cs {
+		res.cs[i] = c.clone()
+	}
+	return res
+}
+
+// Iterate calls f for values in ascending order while f returns true
+func (b *Bitmap) Iterate(f func(x uint32) bool) {
+	for i, c := range b.cs {
+		if !c.iterate(uint32(b.keys[i])<<16, f) {
+			return
+		}
+	}
+}
+
+func (b *Bitmap) ToArray() []uint32 {
+	res := make([]uint32, 0, b.Cardinality())
+	b.Iterate(func(x uint32) bool {
+		res = append(res, x)
+		return true
+	})
+	return res
+}
+
+// And returns intersection of b and o
+func (b *Bitmap) And(o *Bitmap) *Bitmap {
+	res := &Bitmap{}
+	for i, j := 0, 0; i < len(b.keys) && j < len(o.keys); {
+		switch {
+		case b.keys[i] < o.keys[j]:
+			i++
+		case b.keys[i] > o.keys[j]:
+			j++
+		default:
+			if c := containerOp(b.cs[i], o.cs[j], func(x, y uint64) uint64 { return x & y }); c.n > 0 {
+				res.keys = append(res.keys, b.keys[i])
+				res.cs = append(res.cs, c)
+			}
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// Or returns union of b and o
+func (b *Bitmap) Or(o *Bitmap) *Bitmap {
+	res := &Bitmap{}
+	i, j := 0, 0
+	for i < len(b.keys) || j < len(o.keys) {
+		switch {
+		case j == len(o.keys) || (i < len(b.keys) && b.keys[i] < o.keys[j]):
+			res.keys = append(res.keys, b.keys[i])
+			res.cs = append(res.cs, b.cs[i].clone())
+			i++
+		case i == len(b.keys) || b.keys[i] > o.keys[j]:
+			res.keys = append(res.keys, o.keys[j])
+			res.cs = append(res.cs, o.cs[j].clone())
+			j++
+		default:
+			res.keys = append(res.keys, b.keys[i])
+			res.cs = append(res.cs, containerOp(b.cs[i], o.cs[j], func(x, y uint64) uint64 { return x | y }))
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// AndNot returns values of b that are not in o
+func (b *Bitmap) AndNot(o *Bitmap) *Bitmap {
+	res := &Bitmap{}
+	for i := range b.keys {
+		j, ok := o.find(b.keys[i])
+		c := b.cs[i]
+		if ok {
+			c = containerOp(c, o.cs[j], func(x, y uint64) uint64 { return x &^ y })
+		} else {
+			c = c.clone()
+		}
+		if c.n > 0 {
+			res.keys = append(res.keys, b.keys[i])
+			res.cs = append(res.cs, c)
+		}
+	}
+	return res
+}
+
+func (c *container) contains(x uint16) bool {
+	if c.bits != nil {
+		return c.bits[x>>6]&(1<<(x&63)) != 0
+	}
+	i := sort.Search(len(c.arr), func(i int) bool { return c.arr[i] >= x })
+	return i < len(c.arr) && c.arr[i] == x
+}
+
+func (c *container) add(x uint16) {
+	if c.bits != nil {
+		if w := &c.bits[x>>6]; *w&(1<<(x&63)) == 0 {
+			*w |= 1 << (x & 63)
+			c.n++
+		}
+		return
+	}
+	i := sort.Search(len(c.arr), func(i int) bool { return c.arr[i] >= x })
+	if i < len(c.arr) && c.arr[i] == x {
+		return
+	}
+	c.arr = insertItem(c.arr, i, x)
+	c.n++
+	if c.n > arrayMaxValues {
+		c.toBits()
+	}
+}
+
+// remove returns true if x was in container
+func (c *container) remove(x uint16) bool {
+	if c.bits != nil {
+		w := &c.bits[x>>6]
+		if *w&(1<<(x&63)) == 0 {
+			return false
+		}
+		*w &^= 1 << (x & 63)
+		c.n--
+		if c.n <= arrayMaxValues/2 {
+			c.toArray()
+		}
+		return true
+	}
+	i := sort.Search(len(c.arr), func(i int) bool { return c.arr[i] >= x })
+	if i == len(c.arr) || c.arr[i] != x {
+		return false
+	}
+	c.arr = removeItem(c.arr, i)
+	c.n--
+	return true
+}
+
+func (c *container) toBits() {
+	c.bits = make([]uint64, bitmapWords)
+	for _, x := range c.arr {
+		c.bits[x>>6] |= 1 << (x & 63)
+	}
+	c.arr = nil
+}
+
+func (c *container) toArray() {
+	c.arr = make([]uint16, 0, c.n)
+	c.iterate(0, func(x uint32) bool {
+		c.arr = append(c.arr, uint16(x))
+		return true
+	})
+	c.bits = nil
+}
+
+func (c *container) words() []uint64 {
+	if c.bits != nil {
+		return c.bits
+	}
+	w := make([]uint64, bitmapWords)
+	for _, x := range c.arr {
+		w[x>>6] |= 1 << (x & 63)
+	}
+	return w
+}
+
+func (c *container) clone() *container {
+	return &container{
+		arr:  append([]uint16(nil), c.arr...),
+		bits: append([]uint64(nil), c.bits...),
+		n:    c.n,
+	}
+}
+
+func (c *container) iterate(high uint32, f func(x uint32) bool) bool {
+	if c.bits == nil {
+		for _, x := range c.arr {
+			if !f(high | uint32(x)) {
+				return false
+			}
+		}
+		return true
+	}
+	for i, w := range c.bits {
+		for w != 0 {
+			t := bits.TrailingZeros64(w)
+			if !f(high | uint32(i<<6+t)) {
+				return false
+			}
+			w &= w - 1
+		}
+	}
+	return true
+}
+
+// containerOp applies word operation op, sparse results are converted to arrays
+func containerOp(a, b *container, op func(x, y uint64) uint64) *container {
+	if a.bits == nil && b.bits == nil {
+		res := &container{}
+		for i, j := 0, 0; i < len(a.arr) || j < len(b.arr); {
+			var x uint16
+			var ina, inb uint64
+			switch {
+			case j == len(b.arr) || (i < len(a.arr) && a.arr[i] < b.arr[j]):
+				x, ina = a.arr[i], 1
+				i++
+			case i == len(a.arr) || a.arr[i] > b.arr[j]:
+				x, inb = b.arr[j], 1
+				j++
+			default:
+				x, ina, inb = a.arr[i], 1, 1
+				i++
+				j++
+			}
+			if op(ina, inb)&1 != 0 {
+				res.arr = append(res.arr, x)
+			}
+		}
+		res.n = len(res.arr)
+		if res.n > arrayMaxValues {
+			res.toBits()
+		}
+		return res
+	}
+	aw, bw := a.words(), b.words()
+	res := &container{bits: make([]uint64, bitmapWords)}
+	for i := range res.bits {
+		res.bits[i] = op(aw[i], bw[i])
+		res.n += bits.OnesCount64(res.bits[i])
+	}
+	if res.n <= arrayMaxValues {
+		res.toArray()
+	}
+	return res
+}
@@ -0,0 +1,163 @@
+package inmemdb
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func randomBitmap(rnd *rand.Rand, n int, max uint32) (*Bitmap, map[uint32]bool) {
+	b, ref := &Bitmap{}, map[uint32]bool{}
+	for i := 0; i < n; i++ {
+		x := uint32(rnd.Int63n(int64(max)))
+		b.Add(x)
+		ref[x] = true
+	}
+	return b, ref
+}
+
+func checkBitmap(t *testing.T, name string, b *Bitmap, ref map[uint32]bool) {
+	t.Helper()
+	want := make([]uint32, 0, len(ref))
+	for x, ok := range ref {
+		if ok {
+			want = append(want, x)
+		}
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	got := b.ToArray()
+	if len(got) != len(want) || b.Cardinality() != len(want) {
+		t.Fatalf("%s: cardinality %d != %d", name, len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: at %d: %d != %d", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestBitmap(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// sparse and dense containers
+	for _, max := range []uint32{1 << 20, 1 << 17} {
+		a, ra := randomBitmap(rnd, 20000, max)
+		b, rb := randomBitmap(rnd, 20000, max)
+		checkBitmap(t, "a", a, ra)
+
+		and, or, andNot := map[uint32]bool{}, map[uint32]bool{}, map[uint32]bool{}
+		for x := range ra {
+			or[x] = true
+			and[x] = rb[x]
+			andNot[x] = !rb[x]
+		}
+		for x := range rb {
+			or[x] = true
+		}
+		checkBitmap(t, "and", a.And(b), and)
+		checkBitmap(t, "or", a.Or(b), or)
+		checkBitmap(t, "andnot", a.AndNot(b), andNot)
+
+		c := a.Clone()
+		for x := range ra {
+			if rnd.Intn(2) == 0 {
+				c.Remove(x)
+				delete(ra, x)
+			}
+		}
+		checkBitmap(t, "remove", c, ra)
+		if c.Contains(uint32(max)) || len(ra) > 0 && !c.Contains(c.ToArray()[0]) {
+			t.Error("wrong contains")
+		}
+	}
+}
+
+type TestBitmapMO struct {
+	ID     UUIDv4
+	Status string
+	Type   *int
+}
+
+func (t TestBitmapMO) StoreName() string { return "testbitmapmo" }
+
+func TestBitmapIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, tbl *Table[TestBitmapMO]) {
+		status := MustField[TestBitmapMO, string](tbl, "Status")
+		typ := MustField[TestBitmapMO, *int](tbl, "Type")
+		if err := status.CreateBitmapIndex(); err != nil {
+			t.Fatal(err)
+		}
+		mt := tbl.ModelTable()
+		typeIdx, err := mt.CreateBitmapIndex(typ.FD())
+		if err != nil {
+			t.Fatal(err)
+		}
+		statusIdx := mt.BitmapIndex(status.FD())
+
+		one, two := 1, 2
+		rows := []TestBitmapMO{
+			{NewV4(), "a", &one},
+			{NewV4(), "b", &one},
+			{NewV4(), "c", &one},
+			{NewV4(), "a", &two},
+			{NewV4(), "b", nil},
+		}
+		for _, row := range rows {
+			if err := tbl.Insert(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// move row 2 from "c" to "a", delete row 1
+		rows[2].Status = "a"
+		if err := tbl.Insert(rows[2]); err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.Delete(rows[1].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		// status IN (a,b) AND type = 1
+		in, err := statusIdx.In("a", "b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		eq, _ := typeIdx.Bitmap(1)
+		got := collectIDs(mt.BitmapIDs(in.And(eq)))
+		if len(got) != 2 {
+			t.Fatalf("expected 2 rows, got %v", got)
+		}
+		for _, id := range got {
+			if !id.ModelEqual(rows[0].ID) && !id.ModelEqual(rows[2].ID) {
+				t.Errorf("unexpected id %v", id)
+			}
+		}
+		if c, _ := statusIdx.Bitmap("c"); !c.IsEmpty() || statusIdx.Len() != 2 {
+			t.Error("value c must be removed from index")
+		}
+		if n := statusIdx.Not(in).Cardinality(); n != 0 {
+			t.Errorf("expected empty NOT, got %d", n)
+		}
+		// NOT type = 1 is unknown for NULL type
+		if ids := collectIDs(mt.BitmapIDs(typeIdx.Not(eq))); len(ids) != 1 || !ids[0].ModelEqual(rows[3].ID) {
+			t.Errorf("wrong NOT rows: %v", ids)
+		}
+		if ids := collectIDs(mt.BitmapIDs(typeIdx.Nulls())); len(ids) != 1 || !ids[0].ModelEqual(rows[4].ID) {
+			t.Errorf("wrong null rows: %v", ids)
+		}
+		if b := mt.BitmapOf(mt.BitmapIDs(eq)); b.Cardinality() != eq.Cardinality() {
+			t.Error("wrong bitmap of iterator")
+		}
+
+		// returned bitmaps are not changed by further updates
+		nulls := typeIdx.Nulls()
+		rows[0].Type = nil
+		if err := tbl.Insert(rows[0]); err != nil {
+			t.Fatal(err)
+		}
+		if eq.Cardinality() != 2 || nulls.Cardinality() != 1 || typeIdx.Nulls().Cardinality() != 2 {
+			t.Errorf("returned bitmaps are changed: %d %d", eq.Cardinality(), nulls.Cardinality())
+		}
+		if ids := collectIDs(mt.BitmapIDs(typeIdx.Not(eq))); len(ids) != 1 {
+			t.Errorf("wrong NOT rows after update: %v", ids)
+		}
+	})
+}
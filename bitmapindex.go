@@ -0,0 +1,222 @@
+package inmemdb
+
+import (
+	"reflect"
+	"sort"
+)
+
+// RowNumber returns row number of row with id. Row numbers are not changed by updates of row
+// and are reused after delete, they are used as values of Bitmap.
+func (mt *ModelTable) RowNumber(id ModelSortable) (uint32, bool) {
+	i, found := mt.search(id)
+	if !found {
+		return 0, false
+	}
+	return mt.rows.num(i), true
+}
+
+// RowID returns id of row with row number num
+func (mt *ModelTable) RowID(num uint32) (ModelSortable, bool) {
+	return mt.rows.numID(num)
+}
+
+// RowsBitmap returns row numbers of all rows
+func (mt *ModelTable) RowsBitmap() *Bitmap {
+	b := &Bitmap{}
+	for num := uint32(0); num < mt.rows.maxNum(); num++ {
+		if _, ok := mt.rows.numID(num); ok {
+			b.Add(num)
+		}
+	}
+	return b
+}
+
+// BitmapOf returns row numbers of rows with ids from it, unknown ids are skipped
+func (mt *ModelTable) BitmapOf(it IDIterator) *Bitmap {
+	b := &Bitmap{}
+	for it.HasNext() {
+		if num, ok := mt.RowNumber(it.NextID()); ok {
+			b.Add(num)
+		}
+	}
+	return b
+}
+
+// BitmapIDs returns iterator over ids of rows with row numbers from b in ascending order
+func (mt *ModelTable) BitmapIDs(b *Bitmap) *ColumnIterator {
+	ids := make(SortableList, 0, b.Cardinality())
+	b.Iterate(func(num uint32) bool {
+		if id, ok := mt.rows.numID(num); ok {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	sort.Sort(ids)
+	return NewColumnIterator(ids, nil)
+}
+
+// BitmapIndex maps each distinct value of field to Bitmap of row numbers,
+// it suits for fields with a few distinct values. Bitmaps returned by index are copied on the next write,
+// so they are not changed by further updates, but they must not be modified.
+type BitmapIndex struct {
+	mt      *ModelTable
+	fd      *FieldDescription
+	typ     reflect.Type // field type without pointer
+	m       map[interface{}]*sharedBitmap
+	nulls   sharedBitmap
+	present sharedBitmap // rows with not NULL values
+}
+
+// sharedBitmap is a bitmap that is changed in place until it is shared with a caller
+type sharedBitmap struct {
+	b      *Bitmap
+	shared bool
+}
+
+// CreateBitmapIndex creates bitmap index for field fd with comparable values and attaches it to the table
+func (mt *ModelTable) CreateBitmapIndex(fd *FieldDescription) (*BitmapIndex, error) {
+	typ, err := hashableType(mt.md, fd)
+	if err != nil {
+		return nil, err
+	}
+	bi := &BitmapIndex{
+		mt:  mt,
+		fd:  fd,
+		typ: typ,
+		m:   make(map[interface{}]*sharedBitmap),
+	}
+	if err := mt.AttachIndex(bi); err != nil {
+		return nil, err
+	}
+	return bi, nil
+}
+
+// BitmapIndex returns attached bitmap index for field fd or nil
+func (mt *ModelTable) BitmapIndex(fd *FieldDescription) *BitmapIndex {
+	for _, ri := range mt.indexers {
+		if bi, ok := ri.(*BitmapIndex); ok && bi.fd == fd {
+			return bi
+		}
+	}
+	return nil
+}
+
+func (bi *BitmapIndex) FD() *FieldDescription {
+	return bi.fd
+}
+
+// IndexRow is RowIndexer interface, row must be stored in the table
+func (bi *BitmapIndex) IndexRow(id ModelSortable, mo ModelObject) error {
+	num, ok := bi.mt.RowNumber(id)
+	if !ok {
+		return ErrorField{Type: bi.mt.md.ModelType, Field: bi.mt.md.IdField.Name, Value: id, Err: ErrNotFound}
+	}
+	k, isnull, err := hashKey(bi.mt.md, bi.fd, bi.typ, mo.val(bi.fd.Idx))
+	if err != nil {
+		return err
+	}
+	if isnull {
+		bi.nulls.add(num)
+		return nil
+	}
+	b := bi.m[k]
+	if b == nil {
+		b = &sharedBitmap{}
+		bi.m[k] = b
+	}
+	b.add(num)
+	bi.present.add(num)
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (bi *BitmapIndex) UnindexRow(id ModelSortable, mo ModelObject) {
+	num, ok := bi.mt.RowNumber(id)
+	if !ok {
+		return
+	}
+	k, isnull, err := hashKey(bi.mt.md, bi.fd, bi.typ, mo.val(bi.fd.Idx))
+	if err != nil {
+		return
+	}
+	if isnull {
+		bi.nulls.remove(num)
+	} else if b := bi.m[k]; b != nil {
+		if b.remove(num); b.b.IsEmpty() {
+			delete(bi.m, k)
+		}
+		bi.present.remove(num)
+	}
+}
+
+// Bitmap returns row numbers of rows with field value equal to v, v is converted to field type
+func (bi *BitmapIndex) Bitmap(v interface{}) (*Bitmap, error) {
+	k, isnull, err := hashKey(bi.mt.md, bi.fd, bi.typ, v)
+	if err != nil {
+		return nil, err
+	}
+	if isnull {
+		return &Bitmap{}, nil
+	}
+	return bi.m[k].share(), nil
+}
+
+// In returns row numbers of rows with field value equal to any of vs
+func (bi *BitmapIndex) In(vs ...interface{}) (*Bitmap, error) {
+	res := &Bitmap{}
+	for _, v := range vs {
+		b, err := bi.Bitmap(v)
+		if err != nil {
+			return nil, err
+		}
+		res = res.Or(b)
+	}
+	return res, nil
+}
+
+// Not returns row numbers of rows with not NULL field value that are not in b,
+// like NOT in SQL it is unknown for NULL values, so such rows are not returned
+func (bi *BitmapIndex) Not(b *Bitmap) *Bitmap {
+	return bi.present.share().AndNot(b)
+}
+
+// Nulls returns row numbers of rows with NULL field value
+func (bi *BitmapIndex) Nulls() *Bitmap {
+	return bi.nulls.share()
+}
+
+// Len returns number of distinct not NULL values
+func (bi *BitmapIndex) Len() int {
+	return len(bi.m)
+}
+
+// share returns bitmap that is not changed by further updates of sb
+func (sb *sharedBitmap) share() *Bitmap {
+	if sb == nil || sb.b == nil {
+		return &Bitmap{}
+	}
+	sb.shared = true
+	return sb.b
+}
+
+// own copies bitmap shared with callers before change
+func (sb *sharedBitmap) own() {
+	if sb.b == nil {
+		sb.b = &Bitmap{}
+	} else if sb.shared {
+		sb.b, sb.shared = sb.b.Clone(), false
+	}
+}
+
+func (sb *sharedBitmap) add(num uint32) {
+	sb.own()
+	sb.b.Add(num)
+}
+
+func (sb *sharedBitmap) remove(num uint32) {
+	if sb.b == nil || !sb.b.Contains(num) {
+		return
+	}
+	sb.own()
+	sb.b.Remove(num)
+}
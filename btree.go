@@ -0,0 +1,304 @@
+package inmemdb
+
+import (
+	"sort"
+	"unsafe"
+)
+
+const btreeMaxItems = 64
+
+// btree is an order statistic B+ tree: items are addressed by position,
+// inserts, deletes and access by position are O(log n)
+type btree[T any] struct {
+	root *bnode[T]
+}
+
+// bnode items are data items in leaves and first items of children in internal nodes
+type bnode[T any] struct {
+	items    []T
+	children []*bnode[T] // nil for leaves
+	count    int         // number of data items in subtree
+}
+
+func (n *bnode[T]) leaf() bool { return n.children == nil }
+
+func (t *btree[T]) Len() int {
+	if t.root == nil {
+		return 0
+	}
+	return t.root.count
+}
+
+// child returns index of child that contains position i and position in that child
+func (n *bnode[T]) child(i int) (int, int) {
+	last := len(n.children) - 1
+	for j, c := range n.children[:last] {
+		if i < c.count {
+			return j, i
+		}
+		i -= c.count
+	}
+	return last, i
+}
+
+// At returns item at position i, i must be in [0, Len())
+func (t *btree[T]) At(i int) T {
+	n := t.root
+	for !n.leaf() {
+		var j int
+		j, i = n.child(i)
+		n = n.children[j]
+	}
+	return n.items[i]
+}
+
+// Search returns the smallest position i in [0, Len()] at which f is true,
+// f must be false for items before some position and true after, like in sort.Search
+func (t *btree[T]) Search(f func(T) bool) int {
+	pos := 0
+	for n := t.root; n != nil; {
+		k := sort.Search(len(n.items), func(i int) bool { return f(n.items[i]) })
+		if n.leaf() {
+			return pos + k
+		}
+		if k == 0 {
+			return pos
+		}
+		for _, c := range n.children[:k-1] {
+			pos += c.count
+		}
+		n = n.children[k-1]
+	}
+	return pos
+}
+
+// Ascend calls f for items from position i in order while f returns true
+func (t *btree[T]) Ascend(i int, f func(T) bool) {
+	if t.root != nil && i < t.root.count {
+		t.root.ascend(i, f)
+	}
+}
+
+func (n *bnode[T]) ascend(i int, f func(T) bool) bool {
+	if n.leaf() {
+		for _, v := range n.items[i:] {
+			if !f(v) {
+				return false
+			}
+		}
+		return true
+	}
+	j, i := n.child(i)
+	for _, c := range n.children[j:] {
+		if !c.ascend(i, f) {
+			return false
+		}
+		i = 0
+	}
+	return true
+}
+
+// load replaces content of the tree by sorted items in O(n),
+// nodes are filled by 3/4 to leave room for inserts
+func (t *btree[T]) load(items []T) {
+	t.root = nil
+	if len(items) == 0 {
+		return
+	}
+	var level []*bnode[T]
+	for _, c := range loadChunks(len(items)) {
+		level = append(level, &bnode[T]{
+			items: append(make([]T, 0, btreeMaxItems+1), items[c[0]:c[1]]...),
+			count: c[1] - c[0],
+		})
+	}
+	for len(level) > 1 {
+		var next []*bnode[T]
+		for _, c := range loadChunks(len(level)) {
+			n := &bnode[T]{
+				items:    make([]T, 0, btreeMaxItems+1),
+				children: append(make([]*bnode[T], 0, btreeMaxItems+1), level[c[0]:c[1]]...),
+			}
+			for _, ch := range n.children {
+				n.items = append(n.items, ch.items[0])
+				n.count += ch.count
+			}
+			next = append(next, n)
+		}
+		level = next
+	}
+	t.root = level[0]
+}
+
+// loadChunks splits n items into nearly equal ranges of at most 3/4 of node size
+func loadChunks(n int) [][2]int {
+	const fill = btreeMaxItems * 3 / 4
+	cnt := (n + fill - 1) / fill
+	res := make([][2]int, cnt)
+	for i := range res {
+		res[i] = [2]int{i * n / cnt, (i + 1) * n / cnt}
+	}
+	return res
+}
+
+// size returns approximate memory used by nodes of the tree, without memory referenced by items
+func (t *btree[T]) size() int64 {
+	if t.root == nil {
+		return 0
+	}
+	return t.root.size()
+}
+
+func (n *bnode[T]) size() int64 {
+	var zero T
+	sz := int64(unsafe.Sizeof(*n)) + int64(cap(n.items))*int64(unsafe.Sizeof(zero)) +
+		int64(cap(n.children))*int64(unsafe.Sizeof(n))
+	for _, c := range n.children {
+		sz += c.size()
+	}
+	return sz
+}
+
+// Insert inserts v at position i in [0, Len()]
+func (t *btree[T]) Insert(i int, v T) {
+	if t.root == nil {
+		t.root = &bnode[T]{items: make([]T, 0, 8)}
+	}
+	if right := t.root.insert(i, v); right != nil {
+		left := t.root
+		t.root = &bnode[T]{
+			items:    []T{left.items[0], right.items[0]},
+			children: []*bnode[T]{left, right},
+			count:    left.count + right.count,
+		}
+	}
+}
+
+// insert returns new right sibling if node is split
+func (n *bnode[T]) insert(i int, v T) *bnode[T] {
+	n.count++
+	if n.leaf() {
+		n.items = insertItem(n.items, i, v)
+	} else {
+		j, ci := n.child(i)
+		c := n.children[j]
+		right := c.insert(ci, v)
+		n.items[j] = c.items[0]
+		if right != nil {
+			n.items = insertItem(n.items, j+1, right.items[0])
+			n.children = insertItem(n.children, j+1, right)
+		}
+	}
+	if len(n.items) > btreeMaxItems {
+		return n.split()
+	}
+	return nil
+}
+
+func (n *bnode[T]) split() *bnode[T] {
+	h := len(n.items) / 2
+	right := &bnode[T]{items: append(make([]T, 0, btreeMaxItems+1), n.items[h:]...)}
+	clearItems(n.items[h:])
+	n.items = n.items[:h]
+	if n.leaf() {
+		right.count = len(right.items)
+	} else {
+		right.children = append(make([]*bnode[T], 0, btreeMaxItems+1), n.children[h:]...)
+		clearItems(n.children[h:])
+		n.children = n.children[:h]
+		for _, c := range right.children {
+			right.count += c.count
+		}
+	}
+	n.count -= right.count
+	return right
+}
+
+// Set replaces item at position i
+func (t *btree[T]) Set(i int, v T) {
+	t.root.set(i, v)
+}
+
+func (n *bnode[T]) set(i int, v T) {
+	if n.leaf() {
+		n.items[i] = v
+		return
+	}
+	j, ci := n.child(i)
+	n.children[j].set(ci, v)
+	n.items[j] = n.children[j].items[0]
+}
+
+// Delete removes and returns item at position i
+func (t *btree[T]) Delete(i int) T {
+	v := t.root.remove(i)
+	for !t.root.leaf() && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+	}
+	return v
+}
+
+func (n *bnode[T]) remove(i int) T {
+	n.count--
+	if n.leaf() {
+		v := n.items[i]
+		n.items = removeItem(n.items, i)
+		return v
+	}
+	j, ci := n.child(i)
+	c := n.children[j]
+	v := c.remove(ci)
+	if c.count == 0 {
+		n.items = removeItem(n.items, j)
+		n.children = removeItem(n.children, j)
+		return v
+	}
+	n.items[j] = c.items[0]
+	if len(c.items) < btreeMaxItems/4 {
+		n.merge(j)
+	}
+	return v
+}
+
+// merge joins child j with its sibling if they fit into one node
+func (n *bnode[T]) merge(j int) {
+	if j == len(n.children)-1 {
+		j--
+	}
+	if j < 0 {
+		return
+	}
+	l, r := n.children[j], n.children[j+1]
+	if len(l.items)+len(r.items) > btreeMaxItems {
+		return
+	}
+	l.items = append(l.items, r.items...)
+	if !l.leaf() {
+		l.children = append(l.children, r.children...)
+	}
+	l.count += r.count
+	n.items = removeItem(n.items, j+1)
+	n.children = removeItem(n.children, j+1)
+}
+
+func insertItem[T any](s []T, i int, v T) []T {
+	var zero T
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+func removeItem[T any](s []T, i int) []T {
+	copy(s[i:], s[i+1:])
+	var zero T
+	s[len(s)-1] = zero
+	return s[:len(s)-1]
+}
+
+func clearItems[T any](s []T) {
+	var zero T
+	for i := range s {
+		s[i] = zero
+	}
+}
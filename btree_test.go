@@ -0,0 +1,85 @@
+package inmemdb
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var bt btree[int]
+	var ref []int
+	for step := 0; step < 20000; step++ {
+		switch op := rnd.Intn(10); {
+		case op < 6 || len(ref) == 0:
+			v := rnd.Intn(1000)
+			i := bt.Search(func(x int) bool { return x >= v })
+			if j := sort.SearchInts(ref, v); i != j {
+				t.Fatalf("step %d: search %d: %d != %d", step, v, i, j)
+			}
+			bt.Insert(i, v)
+			ref = append(ref[:i], append([]int{v}, ref[i:]...)...)
+		case op < 9:
+			i := rnd.Intn(len(ref))
+			if v := bt.Delete(i); v != ref[i] {
+				t.Fatalf("step %d: deleted %d != %d", step, v, ref[i])
+			}
+			ref = append(ref[:i], ref[i+1:]...)
+		default:
+			i := rnd.Intn(len(ref))
+			bt.Set(i, ref[i])
+		}
+		if bt.Len() != len(ref) {
+			t.Fatalf("step %d: len %d != %d", step, bt.Len(), len(ref))
+		}
+	}
+	for i, v := range ref {
+		if bt.At(i) != v {
+			t.Fatalf("at %d: %d != %d", i, bt.At(i), v)
+		}
+	}
+	from := len(ref) / 2
+	bt.Ascend(from, func(v int) bool {
+		if v != ref[from] {
+			t.Fatalf("ascend %d: %d != %d", from, v, ref[from])
+		}
+		from++
+		return true
+	})
+	if from != len(ref) {
+		t.Errorf("ascend stopped at %d", from)
+	}
+}
+
+func TestBTreeLoad(t *testing.T) {
+	for _, n := range []int{0, 1, 47, 48, 49, 1000, 100000} {
+		items := make([]int, n)
+		for i := range items {
+			items[i] = i * 2
+		}
+		var bt btree[int]
+		bt.load(items)
+		if bt.Len() != n {
+			t.Fatalf("%d: len %d", n, bt.Len())
+		}
+		for i := 0; i < n; i += 7 {
+			if bt.At(i) != i*2 {
+				t.Fatalf("%d: at %d: %d", n, i, bt.At(i))
+			}
+		}
+		// loaded tree remains valid on changes
+		for i := 0; i < n/2; i++ {
+			bt.Insert(bt.Search(func(x int) bool { return x >= i*4+1 }), i*4+1)
+			bt.Delete(bt.Search(func(x int) bool { return x >= i*4 }))
+		}
+		prev := -1
+		bt.Ascend(0, func(v int) bool {
+			if v <= prev {
+				t.Fatalf("%d: wrong order %d after %d", n, v, prev)
+			}
+			prev = v
+			return true
+		})
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	libPath     = "github.com/covrom/inmemdb"
+	genComment  = "// Code generated by inmemdbgen. DO NOT EDIT."
+	storeMethod = "StoreName"
+	lessMethod  = "ModelLess"
+)
+
+// librarySortables are types of inmemdb package that implements ModelSortable
+var librarySortables = map[string]bool{
+	"String":         true,
+	"UUIDv4":         true,
+	"FoldString":     true,
+	"CollatedString": true,
+}
+
+type structDecl struct {
+	st   *ast.StructType
+	file *ast.File
+}
+
+type field struct {
+	Name     string
+	Type     string
+	Sortable bool // type implements ModelSortable
+	Ptr      bool // pointer to sortable type
+}
+
+type model struct {
+	Name         string
+	PtrStoreName bool
+	Fields       []field
+}
+
+type generator struct {
+	fset     *token.FileSet
+	pkgName  string
+	structs  map[string]structDecl
+	storable map[string]bool // type name -> StoreName has pointer receiver
+	sortable map[string]bool
+	imports  map[string]string // path -> name in generated file
+	lib      string
+	models   []model
+}
+
+func newGenerator() *generator {
+	return &generator{
+		fset:     token.NewFileSet(),
+		structs:  make(map[string]structDecl),
+		storable: make(map[string]bool),
+		sortable: make(map[string]bool),
+		imports:  make(map[string]string),
+		lib:      "inmemdb",
+	}
+}
+
+func (g *generator) parseDir(dir string) error {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(g.fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		if err := g.addFile(f); err != nil {
+			return err
+		}
+	}
+	if g.pkgName == "" {
+		return fmt.Errorf("no go files in %s", dir)
+	}
+	return nil
+}
+
+func (g *generator) parseSource(name string, src []byte) error {
+	f, err := parser.ParseFile(g.fset, name, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	return g.addFile(f)
+}
+
+func (g *generator) addFile(f *ast.File) error {
+	for _, cg := range f.Comments {
+		if cg.Pos() < f.Package && strings.HasPrefix(cg.Text(), genComment[3:]) {
+			// skip previously generated files
+			return nil
+		}
+	}
+	if g.pkgName != "" && g.pkgName != f.Name.Name {
+		return fmt.Errorf("multiple packages: %s and %s", g.pkgName, f.Name.Name)
+	}
+	g.pkgName = f.Name.Name
+
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if p == libPath && imp.Name != nil {
+			g.lib = imp.Name.Name
+		}
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && ts.TypeParams == nil {
+					g.structs[ts.Name.Name] = structDecl{st: st, file: f}
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) != 1 {
+				continue
+			}
+			recv, ptr := d.Recv.List[0].Type, false
+			if se, ok := recv.(*ast.StarExpr); ok {
+				recv, ptr = se.X, true
+			}
+			id, ok := recv.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			switch d.Name.Name {
+			case storeMethod:
+				g.storable[id.Name] = ptr
+			case lessMethod:
+				if !ptr {
+					g.sortable[id.Name] = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) generate(types []string) ([]byte, error) {
+	if len(types) == 0 {
+		for name := range g.storable {
+			if _, ok := g.structs[name]; ok {
+				types = append(types, name)
+			}
+		}
+		sort.Strings(types)
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no Storable structs in package %s", g.pkgName)
+	}
+
+	for _, name := range types {
+		name = strings.TrimSpace(name)
+		sd, ok := g.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("struct type %s not found", name)
+		}
+		ptr, ok := g.storable[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s has no %s method", name, storeMethod)
+		}
+		m := model{
+			Name:         name,
+			PtrStoreName: ptr,
+		}
+		if err := g.collectFields(sd, &m.Fields, map[string]bool{name: true}); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		g.models = append(g.models, m)
+	}
+
+	b := &bytes.Buffer{}
+	g.writeHeader(b)
+	for _, m := range g.models {
+		g.writeModel(b, m)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code is invalid: %s\n%s", err, b.String())
+	}
+	return src, nil
+}
+
+// collectFields walks fields like inmemdb.ModelDescription does: embedded structs are flattened,
+// unexported fields and fields with db:"-" tag are skipped
+func (g *generator) collectFields(sd structDecl, fields *[]field, seen map[string]bool) error {
+	for _, f := range sd.st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			s, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(s)
+		}
+
+		if len(f.Names) == 0 {
+			id, ok := f.Type.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("unsupported embedded field %s", g.exprString(f.Type))
+			}
+			if !ast.IsExported(id.Name) {
+				continue
+			}
+			emb, ok := g.structs[id.Name]
+			if !ok || seen[id.Name] {
+				return fmt.Errorf("unsupported embedded field %s", id.Name)
+			}
+			seen[id.Name] = true
+			if err := g.collectFields(emb, fields, seen); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if tag.Get("db") == "-" {
+			continue
+		}
+
+		typ, err := g.typeString(f.Type, sd.file)
+		if err != nil {
+			return err
+		}
+		sortable, ptr := g.isSortable(f.Type, sd.file)
+
+		for _, n := range f.Names {
+			if !n.IsExported() {
+				continue
+			}
+			*fields = append(*fields, field{
+				Name:     n.Name,
+				Type:     typ,
+				Sortable: sortable,
+				Ptr:      ptr,
+			})
+		}
+	}
+	return nil
+}
+
+func (g *generator) isSortable(expr ast.Expr, file *ast.File) (sortable bool, ptr bool) {
+	if se, ok := expr.(*ast.StarExpr); ok {
+		expr, ptr = se.X, true
+	}
+	// instantiated generic types like CollatedString[C]
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		expr = e.X
+	case *ast.IndexListExpr:
+		expr = e.X
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return g.sortable[e.Name], ptr
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && importPath(file, x.Name) == libPath {
+			return librarySortables[e.Sel.Name], ptr
+		}
+	}
+	return false, false
+}
+
+func importPath(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return p
+			}
+			continue
+		}
+		if defaultImportName(p) == name {
+			return p
+		}
+	}
+	return ""
+}
+
+// defaultImportName guesses package name by import path
+func defaultImportName(p string) string {
+	base := path.Base(p)
+	if len(base) > 1 && base[0] == 'v' && strings.IndexFunc(base[1:], func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+		base = path.Base(path.Dir(p))
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	return strings.ReplaceAll(base, "-", "_")
+}
+
+// typeString prints type expression and registers its imports
+func (g *generator) typeString(expr ast.Expr, file *ast.File) (string, error) {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		se, ok := n.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		x, ok := se.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		p := importPath(file, x.Name)
+		if p == "" {
+			err = fmt.Errorf("unknown package %s", x.Name)
+			return false
+		}
+		if p == libPath && x.Name != g.lib {
+			err = fmt.Errorf("%s is imported with different names: %s and %s", libPath, x.Name, g.lib)
+			return false
+		}
+		if prev, ok := g.imports[p]; ok && prev != x.Name {
+			err = fmt.Errorf("%s is imported with different names: %s and %s", p, x.Name, prev)
+			return false
+		}
+		g.imports[p] = x.Name
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+	return g.exprString(expr), nil
+}
+
+func (g *generator) exprString(expr ast.Expr) string {
+	b := &bytes.Buffer{}
+	printer.Fprint(b, g.fset, expr)
+	return b.String()
+}
+
+func (g *generator) writeHeader(b *bytes.Buffer) {
+	fmt.Fprintf(b, "%s\n\npackage %s\n\nimport (\n", genComment, g.pkgName)
+	imports := map[string]string{
+		"fmt":     "fmt",
+		"reflect": "reflect",
+		"sync":    "sync",
+		libPath:   g.lib,
+	}
+	for p, n := range g.imports {
+		imports[p] = n
+	}
+	paths := make([]string, 0, len(imports))
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		// standard library first
+		si, sj := isStdPath(paths[i]), isStdPath(paths[j])
+		if si != sj {
+			return si
+		}
+		return paths[i] < paths[j]
+	})
+	for i, p := range paths {
+		if i > 0 && isStdPath(paths[i-1]) && !isStdPath(p) {
+			b.WriteByte('\n')
+		}
+		if n := imports[p]; n != defaultImportName(p) {
+			fmt.Fprintf(b, "\t%s %q\n", n, p)
+		} else {
+			fmt.Fprintf(b, "\t%q\n", p)
+		}
+	}
+	b.WriteString(")\n")
+}
+
+func isStdPath(p string) bool {
+	return !strings.Contains(strings.SplitN(p, "/", 2)[0], ".")
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	i := 0
+	for i < len(r) && unicode.IsUpper(r[i]) {
+		// lower the leading acronym: ID -> id, URLItem -> urlItem
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+		i++
+	}
+	return string(r)
+}
+
+func (g *generator) writeModel(b *bytes.Buffer, m model) {
+	name := m.Name
+	v := lowerFirst(name) + "Model"
+	lib := g.lib
+
+	names := make([]string, len(m.Fields))
+	for i, f := range m.Fields {
+		names[i] = strconv.Quote(f.Name)
+	}
+
+	storeName := "m.StoreName()"
+	if m.PtrStoreName {
+		storeName = "(&m).StoreName()"
+	}
+
+	fmt.Fprintf(b, `
+var (
+	%[1]sOnce sync.Once
+	%[1]s     *%[2]s.ModelDescription
+	%[1]sErr  error
+	%[1]sIdx  [%[3]d]int // FieldDescription.Idx of stored fields, -1 for not stored
+)
+
+// %[4]sModelDescription returns ModelDescription of %[4]s used by generated accessors
+func %[4]sModelDescription() (*%[2]s.ModelDescription, error) {
+	%[1]sOnce.Do(func() {
+		var m %[4]s
+		%[1]s, %[1]sErr = %[2]s.NewModelDescription(reflect.TypeOf(m), %[5]s)
+		if %[1]sErr != nil {
+			return
+		}
+		for i, name := range [...]string{%[6]s} {
+			%[1]sIdx[i] = -1
+			if fd, ok := %[1]s.ColumnByFieldName[name]; ok && fd.IsStored() {
+				%[1]sIdx[i] = fd.Idx
+			}
+		}
+	})
+	return %[1]s, %[1]sErr
+}
+
+func %[1]sCheck(mo %[2]s.ModelObject) error {
+	md, err := %[4]sModelDescription()
+	if err != nil {
+		return err
+	}
+	if mo.MD() == nil || mo.MD().ModelType != md.ModelType {
+		return fmt.Errorf("model object is not %%s", md.ModelType)
+	}
+	return nil
+}
+
+// ToModelObject implements %[2]s.StructToModel
+func (m %[4]s) ToModelObject(mo %[2]s.ModelObject) error {
+	if err := %[1]sCheck(mo); err != nil {
+		return err
+	}
+`, v, lib, len(m.Fields), name, storeName, strings.Join(names, ", "))
+
+	for i, f := range m.Fields {
+		fmt.Fprintf(b, "\tif i := %sIdx[%d]; i >= 0 {\n\t\tmo.SetFieldAt(i, m.%s)\n\t}\n", v, i, f.Name)
+	}
+	fmt.Fprintf(b, `	return nil
+}
+
+// FromModelObject implements %[2]s.StructFromModel,
+// fields are set into a copy of m, so m is changed only by reflection fallback on error
+func (m *%[3]s) FromModelObject(mo %[2]s.ModelObject) error {
+	if err := %[1]sCheck(mo); err != nil {
+		return err
+	}
+	r := *m
+`, v, lib, name)
+
+	for i, f := range m.Fields {
+		fmt.Fprintf(b, `	if i := %[1]sIdx[%[2]d]; i >= 0 {
+		switch v := mo.FieldAt(i).(type) {
+		case nil:
+		case %[3]s:
+			r.%[4]s = v
+		case %[5]s.NullType:
+			var z %[3]s
+			r.%[4]s = z
+		default:
+			return mo.ToStructReflect(m)
+		}
+	}
+`, v, i, f.Type, f.Name, lib)
+	}
+	b.WriteString("\t*m = r\n\treturn nil\n}\n")
+
+	for i, f := range m.Fields {
+		fmt.Fprintf(b, `
+// %[1]sGet%[2]s returns %[2]s value of mo, ok is false for absent and NULL values
+func %[1]sGet%[2]s(mo %[3]s.ModelObject) (v %[4]s, ok bool) {
+	%[1]sModelDescription()
+	if i := %[5]sIdx[%[6]d]; i >= 0 {
+		v, ok = mo.FieldAt(i).(%[4]s)
+	}
+	return
+}
+
+// %[1]sSet%[2]s sets %[2]s value of mo
+func %[1]sSet%[2]s(mo %[3]s.ModelObject, v %[4]s) {
+	%[1]sModelDescription()
+	if i := %[5]sIdx[%[6]d]; i >= 0 {
+		mo.SetFieldAt(i, v)
+	}
+}
+`, name, f.Name, lib, f.Type, v, i)
+
+		if !f.Sortable {
+			continue
+		}
+		if f.Ptr {
+			fmt.Fprintf(b, `
+// %[1]sLess%[2]s compares %[2]s values, nil is less than any other value
+func %[1]sLess%[2]s(a, b *%[1]s) bool {
+	if a.%[2]s == nil || b.%[2]s == nil {
+		return a.%[2]s == nil && b.%[2]s != nil
+	}
+	return (*a.%[2]s).ModelLess(*b.%[2]s)
+}
+`, name, f.Name)
+		} else {
+			fmt.Fprintf(b, `
+// %[1]sLess%[2]s compares %[2]s values
+func %[1]sLess%[2]s(a, b *%[1]s) bool {
+	return a.%[2]s.ModelLess(b.%[2]s)
+}
+`, name, f.Name)
+		}
+	}
+}
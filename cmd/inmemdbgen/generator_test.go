@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSrc = `package models
+
+import (
+	"time"
+
+	db "github.com/covrom/inmemdb"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+type ruCollation struct{}
+
+func (ruCollation) Collator() *collate.Collator { return collate.New(language.Russian, collate.IgnoreCase) }
+
+type Base struct {
+	ID        db.UUIDv4
+	CreatedAt time.Time
+}
+
+type User struct {
+	Base
+	Name   db.String
+	Note   *db.String
+	Age    int
+	Title  db.FoldString
+	Nick   *db.CollatedString[ruCollation]
+	hidden int
+	Skip   string ` + "`db:\"-\"`" + `
+}
+
+func (User) StoreName() string { return "users" }
+`
+
+func TestGenerate(t *testing.T) {
+	g := newGenerator()
+	if err := g.parseSource("models.go", []byte(testSrc)); err != nil {
+		t.Fatal(err)
+	}
+	src, err := g.generate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(src)
+	for _, s := range []string{
+		`db "github.com/covrom/inmemdb"`,
+		`[...]string{"ID", "CreatedAt", "Name", "Note", "Age", "Title", "Nick"}`,
+		"func (m User) ToModelObject(mo db.ModelObject) error",
+		"func (m *User) FromModelObject(mo db.ModelObject) error",
+		"func UserGetCreatedAt(mo db.ModelObject) (v time.Time, ok bool)",
+		"func UserSetAge(mo db.ModelObject, v int)",
+		"func UserLessNote(a, b *User) bool",
+		"func UserLessTitle(a, b *User) bool",
+		"func UserLessNick(a, b *User) bool",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("generated code must contain %q", s)
+		}
+	}
+	if strings.Contains(out, "UserLessAge") || strings.Contains(out, "hidden") || strings.Contains(out, "Skip") {
+		t.Error("unexpected fields in generated code")
+	}
+}
+
+// roundtripTest compares generated accessors with reflection of inmemdb
+const roundtripTest = `package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	db "github.com/covrom/inmemdb"
+)
+
+func TestRoundtrip(t *testing.T) {
+	md, err := UserModelDescription()
+	if err != nil {
+		t.Fatal(err)
+	}
+	note, nick := db.String("note"), db.CollatedString[ruCollation]("ёж")
+	u := User{Base: Base{ID: db.NewV4(), CreatedAt: time.Now()}, Name: "name", Note: &note, Age: 7, Title: "Title", Nick: &nick}
+
+	gen, refl := db.NewModelObject(md), db.NewModelObject(md)
+	if err := u.ToModelObject(gen); err != nil {
+		t.Fatal(err)
+	}
+	if err := refl.FromStructReflect(u); err != nil {
+		t.Fatal(err)
+	}
+	for _, fd := range md.ColumnPtrs {
+		if !reflect.DeepEqual(gen.FieldAt(fd.Idx), refl.FieldAt(fd.Idx)) {
+			t.Errorf("field %s: %v != %v", fd.Name, gen.FieldAt(fd.Idx), refl.FieldAt(fd.Idx))
+		}
+	}
+
+	// NULL, absent and not exact typed values
+	gen.SetFieldAt(md.ColumnByFieldName["Note"].Idx, db.Null)
+	gen.Delete(md.ColumnByFieldName["Name"])
+	gen.SetFieldAt(md.ColumnByFieldName["Age"].Idx, int64(8))
+	for _, mo := range []db.ModelObject{refl, gen} {
+		got, want := User{Name: "prev"}, User{Name: "prev"}
+		if err := got.FromModelObject(mo); err != nil {
+			t.Fatal(err)
+		}
+		if err := mo.ToStructReflect(&want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v != %+v", got, want)
+		}
+	}
+
+	// comparators of library sortables use their order
+	if !UserLessTitle(&User{Title: "a"}, &User{Title: "B"}) || UserLessTitle(&User{Title: "b"}, &User{Title: "B"}) {
+		t.Error("FoldString must be compared case-insensitive")
+	}
+	zh := db.CollatedString[ruCollation]("жук")
+	if !UserLessNick(&u, &User{Nick: &zh}) || !UserLessNick(&User{}, &u) {
+		t.Error("wrong order of CollatedString")
+	}
+}
+`
+
+func TestGeneratedRoundtrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds generated package")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command is not found")
+	}
+	g := newGenerator()
+	if err := g.parseSource("models.go", []byte(testSrc)); err != nil {
+		t.Fatal(err)
+	}
+	src, err := g.generate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// package must be inside of the module to import inmemdb
+	if err := os.MkdirAll("testdata", 0755); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := os.MkdirTemp("testdata", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		os.Remove("testdata")
+	})
+	for name, data := range map[string]string{
+		"models.go":         testSrc,
+		"models_inmemdb.go": string(src),
+		"models_test.go":    roundtripTest,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"vet", "."}, {"test", "-count=1", "."}} {
+		cmd := exec.Command(gobin, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %s: %v\n%s", args[0], err, out)
+		}
+	}
+}
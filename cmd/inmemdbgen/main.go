@@ -0,0 +1,62 @@
+// Command inmemdbgen generates reflection-free accessors for inmemdb model structs.
+//
+// For every Storable struct (a struct with StoreName method) it emits
+// a cached ModelDescription builder, ToModelObject/FromModelObject methods,
+// which are used by ModelObject.FromStruct and ModelObject.ToStruct instead of reflection,
+// per-field getters/setters over ModelObject and comparators for sortable fields.
+//
+// Usage:
+//
+//	//go:generate go run github.com/covrom/inmemdb/cmd/inmemdbgen -type=User,Order
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("inmemdbgen: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of type names; all Storable structs by default")
+	output := flag.String("output", "", "output file name; default <type>_inmemdb.go or models_inmemdb.go")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+
+	g := newGenerator()
+	if err := g.parseDir(dir); err != nil {
+		log.Fatal(err)
+	}
+	src, err := g.generate(types)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := *output
+	if name == "" {
+		name = "models_inmemdb.go"
+		if len(g.models) == 1 {
+			name = strings.ToLower(g.models[0].Name) + "_inmemdb.go"
+		}
+		name = filepath.Join(dir, name)
+	}
+	if err := ioutil.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintln(os.Stderr, "inmemdbgen: written", name)
+}
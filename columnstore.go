@@ -0,0 +1,324 @@
+package inmemdb
+
+import (
+	"reflect"
+)
+
+// bitset is a growable set of slot numbers
+type bitset []uint64
+
+func (b bitset) get(i uint32) bool {
+	w := int(i >> 6)
+	return w < len(b) && b[w]&(1<<(i&63)) != 0
+}
+
+func (b *bitset) set(i uint32, v bool) {
+	w := int(i >> 6)
+	if w >= len(*b) {
+		if !v {
+			return
+		}
+		*b = append(*b, make([]uint64, w-len(*b)+1)...)
+	}
+	if v {
+		(*b)[w] |= 1 << (i & 63)
+	} else {
+		(*b)[w] &^= 1 << (i & 63)
+	}
+}
+
+// column is a typed vector of stored field values with presence (not absent) and null bitmaps
+type column struct {
+	typ     reflect.Type  // field type without pointer
+	ptr     bool          // field is a pointer, values are returned as pointers to copies
+	vals    reflect.Value // []typ
+	present bitset
+	nulls   bitset
+}
+
+func newColumn(fd *FieldDescription, capacity int) *column {
+	typ := fd.StructField.Type
+	c := &column{typ: typ}
+	if typ.Kind() == reflect.Ptr {
+		c.typ = typ.Elem()
+		c.ptr = true
+	}
+	c.vals = reflect.MakeSlice(reflect.SliceOf(c.typ), 0, capacity)
+	return c
+}
+
+func (c *column) get(slot uint32) interface{} {
+	if !c.present.get(slot) {
+		return nil
+	}
+	if c.nulls.get(slot) {
+		return Null
+	}
+	return c.box(c.vals.Index(int(slot)))
+}
+
+// box returns interface value of field for rv of column type
+func (c *column) box(rv reflect.Value) interface{} {
+	if c.ptr {
+		p := reflect.New(c.typ)
+		p.Elem().Set(rv)
+		return p.Interface()
+	}
+	return rv.Interface()
+}
+
+// value converts v to column type, ok is false for NULL values
+func (c *column) value(v interface{}) (rv reflect.Value, ok bool, err error) {
+	if IsNull(v) {
+		return rv, false, nil
+	}
+	rv = reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.Type().Elem() == c.typ {
+		rv = rv.Elem()
+	}
+	if rv.Type() != c.typ {
+		cv, err := ConvertToType(v, c.typ)
+		if err != nil {
+			return rv, false, err
+		}
+		rv = reflect.ValueOf(cv)
+	}
+	return rv, true, nil
+}
+
+// put stores value converted by value, absent value is cleared
+func (c *column) put(slot uint32, rv reflect.Value, ok, present bool) {
+	if !present {
+		c.clear(slot)
+		return
+	}
+	for c.vals.Len() <= int(slot) {
+		c.vals = reflect.Append(c.vals, reflect.Zero(c.typ))
+	}
+	if ok {
+		c.vals.Index(int(slot)).Set(rv)
+	} else {
+		c.vals.Index(int(slot)).Set(reflect.Zero(c.typ))
+	}
+	c.present.set(slot, true)
+	c.nulls.set(slot, !ok)
+}
+
+func (c *column) clear(slot uint32) {
+	if int(slot) < c.vals.Len() {
+		c.vals.Index(int(slot)).Set(reflect.Zero(c.typ))
+	}
+	c.present.set(slot, false)
+	c.nulls.set(slot, false)
+}
+
+// columnStore keeps stored fields in columns, relation fields are not stored.
+// Rows are placed in slots that are reused after delete, order is a tree of slots sorted by id.
+// Ids are kept boxed by slot, so search and Key don't allocate.
+type columnStore struct {
+	md    *ModelDescription
+	cols  []*column // index is FieldDescription.Idx, nil for not stored fields and id field
+	idc   *column   // type of id field, its values are in ids
+	ids   SortableList
+	views []*slotView // by slot, source of row views until the slot is changed
+	order btree[uint32]
+	free  []uint32
+	slots uint32
+}
+
+func newColumnStore(md *ModelDescription, capacity int) *columnStore {
+	s := &columnStore{
+		md:   md,
+		cols: make([]*column, len(md.ColumnPtrs)),
+		idc:  newColumn(md.IdField, 0),
+		ids:  make(SortableList, 0, capacity),
+	}
+	for _, fd := range md.ColumnPtrs {
+		if fd.IsStored() && fd != md.IdField {
+			s.cols[fd.Idx] = newColumn(fd, capacity)
+		}
+	}
+	return s
+}
+
+func (s *columnStore) len() int { return s.order.Len() }
+
+func (s *columnStore) id(i int) ModelSortable {
+	return s.ids[s.order.At(i)]
+}
+
+func (s *columnStore) search(id ModelSortable) int {
+	return s.order.Search(func(slot uint32) bool {
+		return !s.ids[slot].ModelLess(id)
+	})
+}
+
+func (s *columnStore) field(i int, fd *FieldDescription) interface{} {
+	return s.slotField(s.order.At(i), fd.Idx)
+}
+
+func (s *columnStore) slotField(slot uint32, fdi int) interface{} {
+	if fdi == s.md.IdField.Idx {
+		return s.ids[slot]
+	}
+	if c := s.cols[fdi]; c != nil {
+		return c.get(slot)
+	}
+	return nil
+}
+
+// row returns view of row, its fields are read from columns on first access
+func (s *columnStore) row(i int) ModelObject {
+	mo := NewModelObject(s.md)
+	slot := s.order.At(i)
+	sv := s.views[slot]
+	if sv == nil {
+		sv = &slotView{}
+		s.views[slot] = sv
+	}
+	mo.view = &rowView{s: s, slot: slot, sv: sv}
+	return mo
+}
+
+// slotView is shared by row views of one slot, values of slot are copied to it before the slot is changed,
+// so views return values of the row they were created with
+type slotView struct {
+	vals []interface{} // nil while the slot is not changed
+}
+
+// detach copies values of slot to its views before change of slot
+func (s *columnStore) detach(slot uint32) {
+	if int(slot) >= len(s.views) || s.views[slot] == nil {
+		return
+	}
+	vals := make([]interface{}, len(s.cols))
+	for fdi := range vals {
+		vals[fdi] = s.slotField(slot, fdi)
+	}
+	s.views[slot].vals = vals
+	s.views[slot] = nil
+}
+
+// rowView is a source of fields of ModelObject returned by columnStore, see ModelObject.Load
+type rowView struct {
+	s      *columnStore // nil when all fields are loaded
+	slot   uint32
+	sv     *slotView
+	loaded bitset
+}
+
+// load reads field fdi into v once
+func (w *rowView) load(v []interface{}, fdi int) {
+	if w.s == nil || w.loaded.get(uint32(fdi)) {
+		return
+	}
+	if w.sv.vals != nil {
+		v[fdi] = w.sv.vals[fdi]
+	} else {
+		v[fdi] = w.s.slotField(w.slot, fdi)
+	}
+	w.loaded.set(uint32(fdi), true)
+}
+
+// loadAll reads all not loaded fields and detaches view from store
+func (w *rowView) loadAll(v []interface{}) {
+	if w.s == nil {
+		return
+	}
+	for fdi := range v {
+		w.load(v, fdi)
+	}
+	w.s = nil
+}
+
+// write fills slot with values of mo, slot is not changed on error
+func (s *columnStore) write(slot uint32, mo ModelObject) error {
+	type colValue struct {
+		rv          reflect.Value
+		ok, present bool
+	}
+	// old row view is written back on rollback of upsert
+	mo.Load()
+	idv, _, err := s.idc.value(mo.v[s.md.IdField.Idx])
+	if err != nil {
+		return ErrorField{Type: s.md.ModelType, Field: s.md.IdField.Name, Value: mo.v[s.md.IdField.Idx], Err: err}
+	}
+	id, ok := s.idc.box(idv).(ModelSortable)
+	if !ok {
+		return ErrorField{Type: s.md.ModelType, Field: s.md.IdField.Name, Value: mo.v[s.md.IdField.Idx], Err: ErrNotSortable}
+	}
+	vals := make([]colValue, len(s.cols))
+	for fdi, c := range s.cols {
+		if c == nil || mo.v[fdi] == nil {
+			continue
+		}
+		rv, ok, err := c.value(mo.v[fdi])
+		if err != nil {
+			fd := s.md.ColumnPtrs[fdi]
+			return ErrorField{Type: s.md.ModelType, Field: fd.Name, Value: mo.v[fdi], Err: err}
+		}
+		vals[fdi] = colValue{rv: rv, ok: ok, present: true}
+	}
+	s.detach(slot)
+	for fdi, c := range s.cols {
+		if c != nil {
+			c.put(slot, vals[fdi].rv, vals[fdi].ok, vals[fdi].present)
+		}
+	}
+	for int(slot) >= len(s.ids) {
+		s.ids = append(s.ids, nil)
+		s.views = append(s.views, nil)
+	}
+	s.ids[slot] = id
+	return nil
+}
+
+func (s *columnStore) alloc() uint32 {
+	if ln := len(s.free); ln > 0 {
+		slot := s.free[ln-1]
+		s.free = s.free[:ln-1]
+		return slot
+	}
+	s.slots++
+	return s.slots - 1
+}
+
+func (s *columnStore) release(slot uint32) {
+	s.detach(slot)
+	for _, c := range s.cols {
+		if c != nil {
+			c.clear(slot)
+		}
+	}
+	s.ids[slot] = nil
+	s.free = append(s.free, slot)
+}
+
+func (s *columnStore) insert(i int, mo ModelObject) error {
+	slot := s.alloc()
+	if err := s.write(slot, mo); err != nil {
+		s.free = append(s.free, slot)
+		return err
+	}
+	s.order.Insert(i, slot)
+	return nil
+}
+
+func (s *columnStore) replace(i int, mo ModelObject) error {
+	return s.write(s.order.At(i), mo)
+}
+
+func (s *columnStore) remove(i int) {
+	s.release(s.order.Delete(i))
+}
+
+// slots are stable row numbers
+func (s *columnStore) num(i int) uint32 { return s.order.At(i) }
+func (s *columnStore) maxNum() uint32   { return s.slots }
+
+func (s *columnStore) numID(num uint32) (ModelSortable, bool) {
+	if int(num) >= len(s.ids) || s.ids[num] == nil {
+		return nil, false
+	}
+	return s.ids[num], true
+}
@@ -1,12 +1,43 @@
 package inmemdb
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 
 	"gopkg.in/go-playground/validator.v9"
 )
 
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrNotSortable  = errors.New("value not implements sortable interface")
+	ErrUnknownField = errors.New("unknown field")
+	ErrConstraint   = errors.New("constraint violation")
+	ErrNotHashable  = errors.New("value is not comparable")
+	ErrNotString    = errors.New("value is not a string")
+	ErrNotPoint     = errors.New("value is not a geo point")
+	ErrNotVector    = errors.New("value is not a vector")
+)
+
+// ErrorField is an error for a field of model, it wraps one of Err* values for errors.Is
+type ErrorField struct {
+	Type  reflect.Type
+	Field string
+	Value interface{}
+	Err   error
+}
+
+func (e ErrorField) Error() string {
+	if e.Value != nil {
+		return fmt.Sprintf("%s.%s: %s: %v", e.Type, e.Field, e.Err, e.Value)
+	}
+	return fmt.Sprintf("%s.%s: %s", e.Type, e.Field, e.Err)
+}
+
+func (e ErrorField) Unwrap() error {
+	return e.Err
+}
+
 type ErrorValidation struct {
 	ID interface{}
 
@@ -76,6 +107,38 @@ func MakeErrorValidations(md ModelDescription, modelID interface{}, errs validat
 	return ret, nil
 }
 
+// ErrorRow is an error of one row in bulk loading, Row is the number of row (or line for CSV) starting from 1
+type ErrorRow struct {
+	Row int
+	Err error
+}
+
+func (e ErrorRow) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+func (e ErrorRow) Unwrap() error {
+	return e.Err
+}
+
+// ErrorRows are errors of rows that were not loaded, other rows are loaded
+type ErrorRows []ErrorRow
+
+func (e ErrorRows) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d rows are not loaded, first error: %s", len(e), e[0].Error())
+}
+
+func (e ErrorRows) Unwrap() []error {
+	res := make([]error, len(e))
+	for i := range e {
+		res[i] = e[i]
+	}
+	return res
+}
+
 type ErrorForbidden struct {
 	Message string
 }
@@ -0,0 +1,134 @@
+package inmemdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ExprIndex is a sorted index over keys computed from rows, for example lower(email):
+//
+//	mt.CreateExprIndex("lower_email", func(mo ModelObject) (ModelSortable, error) {
+//		s, _ := mo.Field(email).(String)
+//		return String(strings.ToLower(string(s))), nil
+//	}, nil)
+//
+// Partial index contains only rows matched by its predicate. Key function and predicate
+// must depend on row values only.
+type ExprIndex struct {
+	name string
+	key  func(mo ModelObject) (ModelSortable, error)
+	pred func(mo ModelObject) bool
+	mi   *ModelIndex
+}
+
+// CreateExprIndex creates index named name with keys computed by key and attaches it to the table,
+// nil key is stored as NULL. Only rows matched by pred are indexed, nil pred matches all rows.
+func (mt *ModelTable) CreateExprIndex(name string, key func(mo ModelObject) (ModelSortable, error), pred func(mo ModelObject) bool) (*ExprIndex, error) {
+	if mt.ExprIndex(name) != nil {
+		return nil, fmt.Errorf("%w: index %s already exists", ErrConstraint, name)
+	}
+	ei := &ExprIndex{
+		name: name,
+		key:  key,
+		pred: pred,
+		mi:   NewModelIndex(0),
+	}
+	if err := mt.AttachIndex(ei); err != nil {
+		return nil, err
+	}
+	return ei, nil
+}
+
+// CreatePartialIndex creates index named name on field fd for rows matched by pred
+func (mt *ModelTable) CreatePartialIndex(name string, fd *FieldDescription, pred func(mo ModelObject) bool) (*ExprIndex, error) {
+	if !IsSortableType(fd.StructField.Type) {
+		return nil, ErrorField{Type: mt.md.ModelType, Field: fd.Name, Err: ErrNotSortable}
+	}
+	return mt.CreateExprIndex(name, func(mo ModelObject) (ModelSortable, error) {
+		return indexKey(mt.md, fd, mo.val(fd.Idx))
+	}, pred)
+}
+
+// ExprIndex returns attached expression or partial index with name or nil
+func (mt *ModelTable) ExprIndex(name string) *ExprIndex {
+	for _, ri := range mt.indexers {
+		if ei, ok := ri.(*ExprIndex); ok && ei.name == name {
+			return ei
+		}
+	}
+	return nil
+}
+
+func (ei *ExprIndex) Name() string {
+	return ei.name
+}
+
+// Index returns underlying index, it must not be modified
+func (ei *ExprIndex) Index() *ModelIndex {
+	return ei.mi
+}
+
+// Matches reports whether row mo is in partial index
+func (ei *ExprIndex) Matches(mo ModelObject) bool {
+	return ei.pred == nil || ei.pred(mo)
+}
+
+func (ei *ExprIndex) rowKey(mo ModelObject) (ModelSortable, error) {
+	k, err := ei.key(mo)
+	if err != nil {
+		return nil, err
+	}
+	if IsNull(k) {
+		return Null, nil
+	}
+	return k, nil
+}
+
+// IndexRow is RowIndexer interface
+func (ei *ExprIndex) IndexRow(id ModelSortable, mo ModelObject) error {
+	if !ei.Matches(mo) {
+		return nil
+	}
+	k, err := ei.rowKey(mo)
+	if err != nil {
+		return err
+	}
+	ei.mi.Insert(KV{K: k, V: id})
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (ei *ExprIndex) UnindexRow(id ModelSortable, mo ModelObject) {
+	if !ei.Matches(mo) {
+		return
+	}
+	if k, err := ei.rowKey(mo); err == nil {
+		ei.mi.Delete(KV{K: k, V: id})
+	}
+}
+
+// Len returns number of indexed rows
+func (ei *ExprIndex) Len() int {
+	return ei.mi.Len()
+}
+
+// Eq returns iterator over ids of indexed rows with key equal to k in ascending order
+func (ei *ExprIndex) Eq(k ModelSortable) *ColumnIterator {
+	return ei.mi.Where(OpEq, k)
+}
+
+// Where returns iterator over ids of indexed rows with key matched by op and k in ascending order
+func (ei *ExprIndex) Where(op CompareOp, k ModelSortable) *ColumnIterator {
+	return ei.mi.Where(op, k)
+}
+
+// IDs returns iterator over ids of all indexed rows in ascending order
+func (ei *ExprIndex) IDs() *ColumnIterator {
+	ids := make(SortableList, 0, ei.mi.Len())
+	ei.mi.kvs.Ascend(0, func(kv KV) bool {
+		ids = append(ids, kv.V)
+		return true
+	})
+	sort.Sort(ids)
+	return NewColumnIterator(ids, nil)
+}
@@ -0,0 +1,139 @@
+package inmemdb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type TestUserMO struct {
+	ID     UUIDv4
+	Email  String
+	Active bool
+	Score  *String
+}
+
+func (t TestUserMO) StoreName() string { return "testusermo" }
+
+var errEmptyEmail = errors.New("empty email")
+
+func TestExprIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, tbl *Table[TestUserMO]) {
+		mt := tbl.ModelTable()
+		email := MustField[TestUserMO, String](tbl, "Email")
+		active := MustField[TestUserMO, bool](tbl, "Active")
+		isActive := func(mo ModelObject) bool {
+			v, _ := active.Value(mo)
+			return v
+		}
+		if err := email.CreatePartialIndex("active_email", isActive); err != nil {
+			t.Fatal(err)
+		}
+		lower, err := mt.CreateExprIndex("lower_email", func(mo ModelObject) (ModelSortable, error) {
+			s, _ := email.Value(mo)
+			if s == "" {
+				return nil, errEmptyEmail
+			}
+			return String(strings.ToLower(string(s))), nil
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mt.CreateExprIndex("lower_email", nil, nil); !errors.Is(err, ErrConstraint) {
+			t.Fatalf("expected ErrConstraint, got %v", err)
+		}
+		activeEmail := mt.ExprIndex("active_email")
+
+		users := []TestUserMO{
+			{NewV4(), "Ann@example.com", true, nil},
+			{NewV4(), "bob@example.com", false, nil},
+			{NewV4(), "ann@example.com", false, nil},
+			{NewV4(), "Carl@example.com", true, nil},
+		}
+		for _, u := range users {
+			if err := tbl.Insert(u); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if activeEmail.Len() != 2 || lower.Len() != 4 {
+			t.Fatalf("wrong index sizes %d %d", activeEmail.Len(), lower.Len())
+		}
+		if got := collectIDs(lower.Eq(String("ann@example.com"))); len(got) != 2 {
+			t.Errorf("expected 2 rows by lower(email), got %v", got)
+		}
+		if got := collectIDs(activeEmail.Eq(String("bob@example.com"))); len(got) != 0 {
+			t.Errorf("inactive row must not be indexed: %v", got)
+		}
+
+		// bob becomes active, Ann becomes inactive
+		users[1].Active = true
+		users[0].Active = false
+		for _, u := range users[:2] {
+			if err := tbl.Insert(u); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := collectIDs(activeEmail.Eq(String("bob@example.com"))); len(got) != 1 || !got[0].ModelEqual(users[1].ID) {
+			t.Errorf("wrong active rows: %v", got)
+		}
+		if got := collectIDs(activeEmail.IDs()); len(got) != 2 {
+			t.Errorf("expected 2 active rows, got %v", got)
+		}
+
+		// key error rolls back the row
+		bad := users[3]
+		bad.Email = ""
+		if err := tbl.Insert(bad); !errors.Is(err, errEmptyEmail) {
+			t.Fatalf("expected key error, got %v", err)
+		}
+		if u, _, _ := tbl.Get(bad.ID); u.Email != "Carl@example.com" {
+			t.Errorf("row must not be changed, got %v", u.Email)
+		}
+
+		if err := tbl.Delete(users[2].ID); err != nil {
+			t.Fatal(err)
+		}
+		if got := collectIDs(lower.Eq(String("ann@example.com"))); len(got) != 1 || !got[0].ModelEqual(users[0].ID) {
+			t.Errorf("wrong rows after delete: %v", got)
+		}
+	})
+}
+
+func TestModelIndexWhere(t *testing.T) {
+	tbl, err := NewTable[TestUserMO](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	score := MustField[TestUserMO, *String](tbl, "Score")
+	for _, s := range []string{"a", "b", "b", "c", "d", ""} {
+		u := TestUserMO{ID: NewV4(), Email: "x"}
+		if s != "" {
+			v := String(s)
+			u.Score = &v
+		}
+		if err := tbl.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mt := tbl.ModelTable()
+	for _, op := range []CompareOp{OpEq, OpNe, OpLt, OpLe, OpGt, OpGe} {
+		scan, err := mt.Where(score.FD(), op, String("b"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := collectIDs(scan)
+		if err := score.CreateIndex(); err != nil {
+			t.Fatal(err)
+		}
+		got := collectIDs(mt.idxs[score.FD().Idx].Where(op, String("b")))
+		mt.DeleteIndex(score.FD())
+		if len(got) != len(want) {
+			t.Fatalf("%v: expected %d rows, got %d", op, len(want), len(got))
+		}
+		for i := range got {
+			if !got[i].ModelEqual(want[i]) {
+				t.Fatalf("%v: wrong rows", op)
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package inmemdb
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// FullTextOptions configures FullTextIndex, zero value means SimpleTokenizer without stemming
+type FullTextOptions struct {
+	Tokenizer Tokenizer
+	Stemmer   Stemmer
+}
+
+// FullTextHit is a row found by full-text query
+type FullTextHit struct {
+	ID    ModelSortable
+	Score float64
+}
+
+// FullTextIndex is an inverted index over words of string fields, documents are table rows.
+// Query is a list of clauses, that all must match: word, prefix* or "phrase of words".
+type FullTextIndex struct {
+	mt        *ModelTable
+	fds       []*FieldDescription
+	tokenizer Tokenizer
+	stemmer   Stemmer
+
+	postings map[string]map[uint32][]int // term -> row number -> positions
+	terms    btree[string]               // sorted terms for prefix queries
+	docLens  map[uint32]int
+	totalLen int
+}
+
+// CreateFullTextIndex creates full-text index over string fields fds and attaches it to the table
+func (mt *ModelTable) CreateFullTextIndex(opts FullTextOptions, fds ...*FieldDescription) (*FullTextIndex, error) {
+	for _, fd := range fds {
+		if _, err := stringFieldType(mt.md, fd); err != nil {
+			return nil, err
+		}
+	}
+	fi := &FullTextIndex{
+		mt:        mt,
+		fds:       fds,
+		tokenizer: opts.Tokenizer,
+		stemmer:   opts.Stemmer,
+		postings:  make(map[string]map[uint32][]int),
+		docLens:   make(map[uint32]int),
+	}
+	if fi.tokenizer == nil {
+		fi.tokenizer = SimpleTokenizer{}
+	}
+	if err := mt.AttachIndex(fi); err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+func (fi *FullTextIndex) tokens(text string) []Token {
+	tokens := fi.tokenizer.Tokenize(text)
+	if fi.stemmer != nil {
+		for i := range tokens {
+			tokens[i].Term = fi.stemmer.Stem(tokens[i].Term)
+		}
+	}
+	return tokens
+}
+
+// docTokens returns tokens of all fields, positions of next field start after a gap,
+// so phrases don't cross fields
+func (fi *FullTextIndex) docTokens(mo ModelObject) []Token {
+	var res []Token
+	offset := 0
+	for _, fd := range fi.fds {
+		v := mo.val(fd.Idx)
+		if IsNull(v) {
+			continue
+		}
+		s := reflect.Indirect(reflect.ValueOf(v)).String()
+		tokens := fi.tokens(s)
+		for _, t := range tokens {
+			t.Pos += offset
+			res = append(res, t)
+		}
+		if len(tokens) > 0 {
+			offset = tokens[len(tokens)-1].Pos + offset + 2
+		}
+	}
+	return res
+}
+
+// IndexRow is RowIndexer interface, row must be stored in the table
+func (fi *FullTextIndex) IndexRow(id ModelSortable, mo ModelObject) error {
+	num, ok := fi.mt.RowNumber(id)
+	if !ok {
+		return ErrorField{Type: fi.mt.md.ModelType, Field: fi.mt.md.IdField.Name, Value: id, Err: ErrNotFound}
+	}
+	tokens := fi.docTokens(mo)
+	for _, t := range tokens {
+		docs := fi.postings[t.Term]
+		if docs == nil {
+			docs = make(map[uint32][]int)
+			fi.postings[t.Term] = docs
+			fi.terms.Insert(fi.searchTerm(t.Term), t.Term)
+		}
+		docs[num] = append(docs[num], t.Pos)
+	}
+	fi.docLens[num] = len(tokens)
+	fi.totalLen += len(tokens)
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (fi *FullTextIndex) UnindexRow(id ModelSortable, mo ModelObject) {
+	num, ok := fi.mt.RowNumber(id)
+	if !ok {
+		return
+	}
+	if _, ok := fi.docLens[num]; !ok {
+		return
+	}
+	for _, t := range fi.docTokens(mo) {
+		docs := fi.postings[t.Term]
+		if docs == nil {
+			continue
+		}
+		delete(docs, num)
+		if len(docs) == 0 {
+			delete(fi.postings, t.Term)
+			fi.terms.Delete(fi.searchTerm(t.Term))
+		}
+	}
+	fi.totalLen -= fi.docLens[num]
+	delete(fi.docLens, num)
+}
+
+func (fi *FullTextIndex) searchTerm(term string) int {
+	return fi.terms.Search(func(t string) bool { return t >= term })
+}
+
+// prefixTerms returns indexed terms that start with prefix
+func (fi *FullTextIndex) prefixTerms(prefix string) []string {
+	var res []string
+	fi.terms.Ascend(fi.searchTerm(prefix), func(t string) bool {
+		if !strings.HasPrefix(t, prefix) {
+			return false
+		}
+		res = append(res, t)
+		return true
+	})
+	return res
+}
+
+type ftClause struct {
+	terms  []string
+	prefix bool
+	phrase bool
+}
+
+// parseQuery splits query into clauses: "quoted phrases", prefixes* and words,
+// prefixes are not stemmed
+func (fi *FullTextIndex) parseQuery(query string) []ftClause {
+	var res []ftClause
+	for len(query) > 0 {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if len(query) == 0 {
+			break
+		}
+		if query[0] == '"' {
+			end := strings.IndexByte(query[1:], '"')
+			rest := ""
+			if end < 0 {
+				end = len(query) - 1
+			} else {
+				rest = query[end+2:]
+			}
+			if tokens := fi.tokens(query[1 : end+1]); len(tokens) > 0 {
+				c := ftClause{phrase: true}
+				for _, t := range tokens {
+					c.terms = append(c.terms, t.Term)
+				}
+				res = append(res, c)
+			}
+			query = rest
+			continue
+		}
+		end := strings.IndexFunc(query, unicode.IsSpace)
+		if end < 0 {
+			end = len(query)
+		}
+		word := query[:end]
+		query = query[end:]
+		if strings.HasSuffix(word, "*") {
+			if tokens := fi.tokenizer.Tokenize(strings.TrimSuffix(word, "*")); len(tokens) == 1 {
+				res = append(res, ftClause{terms: []string{tokens[0].Term}, prefix: true})
+				continue
+			}
+		}
+		for _, t := range fi.tokens(word) {
+			res = append(res, ftClause{terms: []string{t.Term}})
+		}
+	}
+	return res
+}
+
+// match returns row numbers of clause and terms that are used for scoring
+func (fi *FullTextIndex) match(c ftClause) (*Bitmap, []string) {
+	res := &Bitmap{}
+	switch {
+	case c.prefix:
+		terms := fi.prefixTerms(c.terms[0])
+		for _, t := range terms {
+			for num := range fi.postings[t] {
+				res.Add(num)
+			}
+		}
+		return res, terms
+	case c.phrase:
+		first := fi.postings[c.terms[0]]
+	docs:
+		for num, positions := range first {
+			for _, p := range positions {
+				ok := true
+				for i, t := range c.terms[1:] {
+					if !containsInt(fi.postings[t][num], p+i+1) {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					res.Add(num)
+					continue docs
+				}
+			}
+		}
+		return res, c.terms
+	}
+	for num := range fi.postings[c.terms[0]] {
+		res.Add(num)
+	}
+	return res, c.terms
+}
+
+func containsInt(s []int, x int) bool {
+	i := sort.SearchInts(s, x)
+	return i < len(s) && s[i] == x
+}
+
+// Match returns row numbers of rows that match all clauses of query
+func (fi *FullTextIndex) Match(query string) *Bitmap {
+	res, _ := fi.matchQuery(query)
+	return res
+}
+
+func (fi *FullTextIndex) matchQuery(query string) (*Bitmap, []string) {
+	clauses := fi.parseQuery(query)
+	if len(clauses) == 0 {
+		return &Bitmap{}, nil
+	}
+	var res *Bitmap
+	var terms []string
+	for _, c := range clauses {
+		b, cterms := fi.match(c)
+		if res == nil {
+			res = b
+		} else {
+			res = res.And(b)
+		}
+		terms = append(terms, cterms...)
+		if res.IsEmpty() {
+			break
+		}
+	}
+	return res, terms
+}
+
+// IDs returns iterator over ids of rows that match query in ascending order
+func (fi *FullTextIndex) IDs(query string) *ColumnIterator {
+	return fi.mt.BitmapIDs(fi.Match(query))
+}
+
+// Search returns rows that match query ordered by BM25 score descending, limit <= 0 means all rows
+func (fi *FullTextIndex) Search(query string, limit int) []FullTextHit {
+	docs, terms := fi.matchQuery(query)
+	if docs.IsEmpty() {
+		return nil
+	}
+	n := float64(len(fi.docLens))
+	avgLen := float64(fi.totalLen) / n
+	idfs := make([]float64, len(terms))
+	for i, t := range terms {
+		df := float64(len(fi.postings[t]))
+		idfs[i] = math.Log(1 + (n-df+0.5)/(df+0.5))
+	}
+
+	res := make([]FullTextHit, 0, docs.Cardinality())
+	docs.Iterate(func(num uint32) bool {
+		id, ok := fi.mt.RowID(num)
+		if !ok {
+			return true
+		}
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(fi.docLens[num])/avgLen)
+		score := 0.0
+		for i, t := range terms {
+			if tf := float64(len(fi.postings[t][num])); tf > 0 {
+				score += idfs[i] * tf * (bm25K1 + 1) / (tf + norm)
+			}
+		}
+		res = append(res, FullTextHit{ID: id, Score: score})
+		return true
+	})
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return SortableLess(res[i].ID, res[j].ID)
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
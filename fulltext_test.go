@@ -0,0 +1,140 @@
+package inmemdb
+
+import (
+	"errors"
+	"testing"
+)
+
+type TestTextMO struct {
+	ID    UUIDv4
+	Title string
+	Body  *string
+	Kind  string
+	Size  int
+}
+
+func (t TestTextMO) StoreName() string { return "testtextmo" }
+
+func TestStemmers(t *testing.T) {
+	tokens := SimpleTokenizer{MinLength: 2}.Tokenize("Hello, мир! A cat-walk")
+	if len(tokens) != 4 || tokens[0].Term != "hello" || tokens[1].Term != "мир" || tokens[3].Term != "walk" || tokens[3].Pos != 3 {
+		t.Errorf("wrong tokens: %v", tokens)
+	}
+	for word, stem := range map[string]string{
+		"searching": "search",
+		"searches":  "search",
+		"class":     "class",
+		"поиска":    "поиск",
+		"поиском":   "поиск",
+		"ёлки":      "елк",
+	} {
+		if got := LanguageStemmer.Stem(word); got != stem {
+			t.Errorf("stem %q: expected %q, got %q", word, stem, got)
+		}
+	}
+}
+
+func TestFullTextIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, tbl *Table[TestTextMO]) {
+		mt := tbl.ModelTable()
+		title := MustField[TestTextMO, string](tbl, "Title")
+		body := MustField[TestTextMO, *string](tbl, "Body")
+		size := MustField[TestTextMO, int](tbl, "Size")
+		if _, err := mt.CreateFullTextIndex(FullTextOptions{}, size.FD()); !errors.Is(err, ErrNotString) {
+			t.Fatalf("expected ErrNotString, got %v", err)
+		}
+
+		str := func(s string) *string { return &s }
+		rows := []TestTextMO{
+			{NewV4(), "Quick brown fox", str("The fox jumps over the lazy dog"), "en", 1},
+			{NewV4(), "Searching texts", str("Full text searches with stemming"), "en", 2},
+			{NewV4(), "Полнотекстовый поиск", str("Поиск по русским текстам"), "ru", 3},
+			{NewV4(), "Brown dog", nil, "en", 4},
+		}
+		for _, row := range rows[:2] {
+			if err := tbl.Insert(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// index is filled with existing rows
+		fi, err := mt.CreateFullTextIndex(FullTextOptions{Stemmer: LanguageStemmer}, title.FD(), body.FD())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows[2:] {
+			if err := tbl.Insert(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expect := func(query string, idx ...int) {
+			t.Helper()
+			got := collectIDs(fi.IDs(query))
+			if len(got) != len(idx) {
+				t.Fatalf("%q: expected %d rows, got %v", query, len(idx), got)
+			}
+			for _, i := range idx {
+				found := false
+				for _, id := range got {
+					found = found || id.ModelEqual(rows[i].ID)
+				}
+				if !found {
+					t.Errorf("%q: row %d not found", query, i)
+				}
+			}
+			for i := 1; i < len(got); i++ {
+				if !SortableLess(got[i-1], got[i]) {
+					t.Errorf("%q: ids are not ascending", query)
+				}
+			}
+		}
+		expect("brown", 0, 3)
+		expect("brown dog", 0, 3)
+		expect(`"brown dog"`, 3)
+		expect(`"lazy dog" fox`, 0)
+		expect(`"fox the"`)
+		expect("search", 1)
+		expect("ПОИСКОМ", 2)
+		expect("текст*", 2)
+		expect("text*", 1)
+		expect("unknown")
+		expect("")
+
+		hits := fi.Search("dog", 0)
+		if len(hits) != 2 || !hits[0].ID.ModelEqual(rows[3].ID) || hits[0].Score <= hits[1].Score {
+			t.Errorf("wrong ranking: %v", hits)
+		}
+		if hits := fi.Search("brown", 1); len(hits) != 1 {
+			t.Errorf("limit is not applied: %v", hits)
+		}
+
+		rows[3].Title = "Red cat"
+		if err := tbl.Insert(rows[3]); err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.Delete(rows[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		expect("brown")
+		expect("dog")
+		expect("cat", 3)
+		if fi.terms.Len() != len(fi.postings) {
+			t.Errorf("terms are not synced: %d != %d", fi.terms.Len(), len(fi.postings))
+		}
+
+		kind := MustField[TestTextMO, string](tbl, "Kind")
+		if err := kind.CreateHashIndex(); err != nil {
+			t.Fatal(err)
+		}
+		en, err := kind.Eq("en")
+		if err != nil {
+			t.Fatal(err)
+		}
+		inter := NewIteratorIntersect()
+		inter.Append(en)
+		inter.Append(fi.IDs("text*"))
+		if got := collectIDs(inter); len(got) != 1 || !got[0].ModelEqual(rows[1].ID) {
+			t.Errorf("wrong intersection: %v", got)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package inmemdb
+
+import (
+	"reflect"
+)
+
+var pointType = reflect.TypeOf(Point{})
+
+// GeoIndex is an R-tree spatial index on Point field, NULL points are not indexed
+type GeoIndex struct {
+	mt *ModelTable
+	fd *FieldDescription
+	t  rtree
+}
+
+// GeoHit is a row found by nearest neighbour query
+type GeoHit struct {
+	ID       ModelSortable
+	Point    Point
+	Distance float64 // meters
+}
+
+// CreateGeoIndex creates spatial index for Point field fd and attaches it to the table
+func (mt *ModelTable) CreateGeoIndex(fd *FieldDescription) (*GeoIndex, error) {
+	typ := fd.StructField.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if !fd.IsStored() || typ != pointType {
+		return nil, ErrorField{Type: mt.md.ModelType, Field: fd.Name, Err: ErrNotPoint}
+	}
+	gi := &GeoIndex{
+		mt: mt,
+		fd: fd,
+	}
+	if err := mt.AttachIndex(gi); err != nil {
+		return nil, err
+	}
+	return gi, nil
+}
+
+// GeoIndex returns attached spatial index for field fd or nil
+func (mt *ModelTable) GeoIndex(fd *FieldDescription) *GeoIndex {
+	for _, ri := range mt.indexers {
+		if gi, ok := ri.(*GeoIndex); ok && gi.fd == fd {
+			return gi
+		}
+	}
+	return nil
+}
+
+func (gi *GeoIndex) FD() *FieldDescription {
+	return gi.fd
+}
+
+// Len returns number of indexed points
+func (gi *GeoIndex) Len() int {
+	return gi.t.Len()
+}
+
+func (gi *GeoIndex) point(v interface{}) (Point, bool) {
+	switch p := v.(type) {
+	case Point:
+		return p, true
+	case *Point:
+		if p != nil {
+			return *p, true
+		}
+	}
+	return Point{}, false
+}
+
+// IndexRow is RowIndexer interface, row must be stored in the table
+func (gi *GeoIndex) IndexRow(id ModelSortable, mo ModelObject) error {
+	num, ok := gi.mt.RowNumber(id)
+	if !ok {
+		return ErrorField{Type: gi.mt.md.ModelType, Field: gi.mt.md.IdField.Name, Value: id, Err: ErrNotFound}
+	}
+	p, ok := gi.point(mo.val(gi.fd.Idx))
+	if !ok {
+		return nil
+	}
+	if !p.Valid() {
+		return ErrorField{Type: gi.mt.md.ModelType, Field: gi.fd.Name, Value: p, Err: ErrConstraint}
+	}
+	gi.t.Insert(p, num)
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (gi *GeoIndex) UnindexRow(id ModelSortable, mo ModelObject) {
+	num, ok := gi.mt.RowNumber(id)
+	if !ok {
+		return
+	}
+	if p, ok := gi.point(mo.val(gi.fd.Idx)); ok {
+		gi.t.Delete(p, num)
+	}
+}
+
+// BBoxBitmap returns row numbers of rows with points inside r
+func (gi *GeoIndex) BBoxBitmap(r Rect) *Bitmap {
+	b := &Bitmap{}
+	add := func(p Point, num uint32) bool {
+		b.Add(num)
+		return true
+	}
+	if r.MinLon > r.MaxLon {
+		// split by the antimeridian
+		gi.t.Search(Rect{MinLat: r.MinLat, MinLon: r.MinLon, MaxLat: r.MaxLat, MaxLon: 180}, add)
+		gi.t.Search(Rect{MinLat: r.MinLat, MinLon: -180, MaxLat: r.MaxLat, MaxLon: r.MaxLon}, add)
+	} else {
+		gi.t.Search(r, add)
+	}
+	return b
+}
+
+// BBox returns iterator over ids of rows with points inside r in ascending order
+func (gi *GeoIndex) BBox(r Rect) IDIterator {
+	return gi.mt.BitmapIDs(gi.BBoxBitmap(r))
+}
+
+// RadiusBitmap returns row numbers of rows with points within distance in meters from center
+func (gi *GeoIndex) RadiusBitmap(center Point, meters float64) *Bitmap {
+	b := &Bitmap{}
+	r := RadiusRect(center, meters)
+	check := func(p Point, num uint32) bool {
+		if center.Distance(p) <= meters {
+			b.Add(num)
+		}
+		return true
+	}
+	if r.MinLon > r.MaxLon {
+		gi.t.Search(Rect{MinLat: r.MinLat, MinLon: r.MinLon, MaxLat: r.MaxLat, MaxLon: 180}, check)
+		gi.t.Search(Rect{MinLat: r.MinLat, MinLon: -180, MaxLat: r.MaxLat, MaxLon: r.MaxLon}, check)
+	} else {
+		gi.t.Search(r, check)
+	}
+	return b
+}
+
+// Radius returns iterator over ids of rows with points within distance in meters from center in ascending order
+func (gi *GeoIndex) Radius(center Point, meters float64) IDIterator {
+	return gi.mt.BitmapIDs(gi.RadiusBitmap(center, meters))
+}
+
+// Nearest returns k rows with points nearest to center ordered by distance
+func (gi *GeoIndex) Nearest(center Point, k int) []GeoHit {
+	var res []GeoHit
+	if k <= 0 {
+		return res
+	}
+	gi.t.Nearest(center, func(it rtreeItem, meters float64) bool {
+		if id, ok := gi.mt.RowID(it.num); ok {
+			res = append(res, GeoHit{ID: id, Point: it.p, Distance: meters})
+		}
+		return len(res) < k
+	})
+	return res
+}
+
+// NearestIDs is like Nearest, but returns iterator over ids in ascending order
+func (gi *GeoIndex) NearestIDs(center Point, k int) IDIterator {
+	b := &Bitmap{}
+	for _, hit := range gi.Nearest(center, k) {
+		if num, ok := gi.mt.RowNumber(hit.ID); ok {
+			b.Add(num)
+		}
+	}
+	return gi.mt.BitmapIDs(b)
+}
@@ -0,0 +1,145 @@
+package inmemdb
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type TestPlaceMO struct {
+	ID       UUIDv4
+	Kind     string
+	Location *Point
+}
+
+func (t TestPlaceMO) StoreName() string { return "testplacemo" }
+
+func TestPoint(t *testing.T) {
+	var p Point
+	if err := p.Scan("POINT(37.6173 55.7558)"); err != nil || p != (Point{Lat: 55.7558, Lon: 37.6173}) {
+		t.Fatalf("wrong WKT scan: %v %v", p, err)
+	}
+	if v, _ := p.Value(); v != "POINT(37.6173 55.7558)" {
+		t.Errorf("wrong value: %v", v)
+	}
+	var q Point
+	if err := q.Scan([]byte("(37.6173,55.7558)")); err != nil || q != p {
+		t.Errorf("wrong point scan: %v %v", q, err)
+	}
+	if err := q.Scan("POINT(1)"); err == nil {
+		t.Error("expected scan error")
+	}
+	if v, err := ConvertToType([]float64{55.7558, 37.6173}, pointType); err != nil || v != p {
+		t.Errorf("wrong conversion: %v %v", v, err)
+	}
+	// Moscow - Saint Petersburg
+	if d := p.Distance(Point{Lat: 59.9343, Lon: 30.3351}); math.Abs(d-634000) > 2000 {
+		t.Errorf("wrong distance %v", d)
+	}
+}
+
+func TestGeoIndex(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	forEachStore(t, func(t *testing.T, tbl *Table[TestPlaceMO]) {
+		mt := tbl.ModelTable()
+		loc := MustField[TestPlaceMO, *Point](tbl, "Location")
+		kind := MustField[TestPlaceMO, string](tbl, "Kind")
+		if _, err := mt.CreateGeoIndex(kind.FD()); !errors.Is(err, ErrNotPoint) {
+			t.Fatalf("expected ErrNotPoint, got %v", err)
+		}
+		if err := loc.CreateGeoIndex(); err != nil {
+			t.Fatal(err)
+		}
+		gi := mt.GeoIndex(loc.FD())
+
+		places := make(map[UUIDv4]TestPlaceMO)
+		var ids []UUIDv4
+		for i := 0; i < 2000; i++ {
+			p := Point{Lat: rnd.Float64()*180 - 90, Lon: rnd.Float64()*360 - 180}
+			pl := TestPlaceMO{ID: NewV4(), Kind: []string{"cafe", "shop"}[i%2], Location: &p}
+			if i%50 == 0 {
+				pl.Location = nil
+			}
+			if err := tbl.Insert(pl); err != nil {
+				t.Fatal(err)
+			}
+			places[pl.ID] = pl
+			ids = append(ids, pl.ID)
+		}
+		// move and delete some places
+		for _, id := range ids[:300] {
+			if rnd.Intn(2) == 0 {
+				delete(places, id)
+				if err := tbl.Delete(id); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			pl := places[id]
+			p := Point{Lat: rnd.Float64()*180 - 90, Lon: rnd.Float64()*360 - 180}
+			pl.Location = &p
+			places[id] = pl
+			if err := tbl.Insert(pl); err != nil {
+				t.Fatal(err)
+			}
+		}
+		bad := TestPlaceMO{ID: NewV4(), Location: &Point{Lat: 100}}
+		if err := tbl.Insert(bad); !errors.Is(err, ErrConstraint) {
+			t.Fatalf("expected ErrConstraint, got %v", err)
+		}
+
+		check := func(name string, it IDIterator, match func(pl TestPlaceMO) bool) {
+			t.Helper()
+			got := make(map[UUIDv4]bool)
+			for _, id := range collectIDs(it) {
+				got[id.(UUIDv4)] = true
+			}
+			n := 0
+			for id, pl := range places {
+				if pl.Location != nil && match(pl) {
+					n++
+					if !got[id] {
+						t.Fatalf("%s: %v not found", name, pl.Location)
+					}
+				}
+			}
+			if n != len(got) {
+				t.Fatalf("%s: expected %d rows, got %d", name, n, len(got))
+			}
+		}
+		box := Rect{MinLat: -20, MinLon: 10, MaxLat: 30, MaxLon: 60}
+		check("bbox", gi.BBox(box), func(pl TestPlaceMO) bool { return box.Contains(*pl.Location) })
+		cross := Rect{MinLat: -40, MinLon: 150, MaxLat: 40, MaxLon: -150}
+		check("antimeridian bbox", gi.BBox(cross), func(pl TestPlaceMO) bool { return cross.Contains(*pl.Location) })
+		for _, c := range []Point{{Lat: 10, Lon: 20}, {Lat: 0, Lon: 179.5}, {Lat: 89, Lon: 0}} {
+			check("radius", gi.Radius(c, 1500000), func(pl TestPlaceMO) bool { return c.Distance(*pl.Location) <= 1500000 })
+		}
+
+		for _, c := range []Point{{Lat: 55, Lon: 37}, {Lat: -60, Lon: -179}} {
+			hits := gi.Nearest(c, 10)
+			if len(hits) != 10 {
+				t.Fatalf("expected 10 nearest, got %d", len(hits))
+			}
+			for i := 1; i < len(hits); i++ {
+				if hits[i].Distance < hits[i-1].Distance {
+					t.Fatal("nearest are not ordered by distance")
+				}
+			}
+			far := hits[len(hits)-1].Distance
+			check("nearest", gi.NearestIDs(c, 10), func(pl TestPlaceMO) bool { return c.Distance(*pl.Location) <= far })
+		}
+
+		if err := kind.CreateHashIndex(); err != nil {
+			t.Fatal(err)
+		}
+		cafes, err := kind.Eq("cafe")
+		if err != nil {
+			t.Fatal(err)
+		}
+		inter := NewIteratorIntersect()
+		inter.Append(cafes)
+		inter.Append(gi.BBox(box))
+		check("intersection", inter, func(pl TestPlaceMO) bool { return pl.Kind == "cafe" && box.Contains(*pl.Location) })
+	})
+}
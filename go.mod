@@ -1,14 +1,18 @@
 module github.com/covrom/inmemdb
 
-go 1.13
+go 1.18
 
 require (
-	github.com/go-playground/locales v0.13.0 // indirect
-	github.com/go-playground/universal-translator v0.16.0 // indirect
 	github.com/google/uuid v1.1.1
 	github.com/jmoiron/sqlx v1.2.0
+	golang.org/x/text v0.14.0
+	gopkg.in/go-playground/validator.v9 v9.30.0
+)
+
+require (
+	github.com/go-playground/locales v0.13.0 // indirect
+	github.com/go-playground/universal-translator v0.16.0 // indirect
 	github.com/leodido/go-urn v1.2.0 // indirect
 	google.golang.org/appengine v1.6.5 // indirect
 	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
-	gopkg.in/go-playground/validator.v9 v9.30.0
 )
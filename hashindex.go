@@ -0,0 +1,201 @@
+package inmemdb
+
+import (
+	"reflect"
+	"sort"
+)
+
+// HashIndex is an equality index on a field with comparable values,
+// values need not implement ModelSortable. Lists of ids returned by IDs are copied
+// on the next write, so iterators are not changed by further updates.
+type HashIndex struct {
+	md    *ModelDescription
+	fd    *FieldDescription
+	typ   reflect.Type // field type without pointer
+	m     map[interface{}]*idList
+	nulls idList
+}
+
+// idList is a sorted list of ids that is changed in place until it is shared with an iterator
+type idList struct {
+	ids    SortableList
+	shared bool
+}
+
+// NewHashIndex creates empty hash index for field fd, use ModelTable.AttachIndex to fill it
+func NewHashIndex(md *ModelDescription, fd *FieldDescription) (*HashIndex, error) {
+	typ, err := hashableType(md, fd)
+	if err != nil {
+		return nil, err
+	}
+	return &HashIndex{
+		md:  md,
+		fd:  fd,
+		typ: typ,
+		m:   make(map[interface{}]*idList),
+	}, nil
+}
+
+// CreateHashIndex creates hash index for field fd and attaches it to the table
+func (mt *ModelTable) CreateHashIndex(fd *FieldDescription) (*HashIndex, error) {
+	hi, err := NewHashIndex(mt.md, fd)
+	if err != nil {
+		return nil, err
+	}
+	if err := mt.AttachIndex(hi); err != nil {
+		return nil, err
+	}
+	return hi, nil
+}
+
+// HashIndex returns attached hash index for field fd or nil
+func (mt *ModelTable) HashIndex(fd *FieldDescription) *HashIndex {
+	for _, ri := range mt.indexers {
+		if hi, ok := ri.(*HashIndex); ok && hi.fd == fd {
+			return hi
+		}
+	}
+	return nil
+}
+
+func (hi *HashIndex) FD() *FieldDescription {
+	return hi.fd
+}
+
+// hashableType returns type of map keys for field values
+func hashableType(md *ModelDescription, fd *FieldDescription) (reflect.Type, error) {
+	typ := fd.StructField.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if !fd.IsStored() || !typ.Comparable() {
+		return nil, ErrorField{Type: md.ModelType, Field: fd.Name, Err: ErrNotHashable}
+	}
+	return typ, nil
+}
+
+// hashKey returns map key of type typ for field value, isnull is true for NULL values
+func hashKey(md *ModelDescription, fd *FieldDescription, typ reflect.Type, v interface{}) (k interface{}, isnull bool, err error) {
+	if IsNull(v) {
+		return nil, true, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.Type().Elem() == typ {
+		return rv.Elem().Interface(), false, nil
+	}
+	if rv.Type() == typ {
+		return checkHashable(md, fd, v)
+	}
+	cv, err := ConvertToType(v, typ)
+	if err != nil {
+		return nil, false, ErrorField{Type: md.ModelType, Field: fd.Name, Value: v, Err: err}
+	}
+	return checkHashable(md, fd, cv)
+}
+
+// checkHashable checks dynamic type of value, interface fields may hold slices and maps
+func checkHashable(md *ModelDescription, fd *FieldDescription, v interface{}) (interface{}, bool, error) {
+	if !reflect.TypeOf(v).Comparable() {
+		return nil, false, ErrorField{Type: md.ModelType, Field: fd.Name, Value: v, Err: ErrNotHashable}
+	}
+	return v, false, nil
+}
+
+func (hi *HashIndex) key(v interface{}) (interface{}, bool, error) {
+	return hashKey(hi.md, hi.fd, hi.typ, v)
+}
+
+// IndexRow is RowIndexer interface
+func (hi *HashIndex) IndexRow(id ModelSortable, mo ModelObject) error {
+	k, isnull, err := hi.key(mo.val(hi.fd.Idx))
+	if err != nil {
+		return err
+	}
+	if isnull {
+		hi.nulls.insert(id)
+		return nil
+	}
+	l := hi.m[k]
+	if l == nil {
+		l = &idList{}
+		hi.m[k] = l
+	}
+	l.insert(id)
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (hi *HashIndex) UnindexRow(id ModelSortable, mo ModelObject) {
+	k, isnull, err := hi.key(mo.val(hi.fd.Idx))
+	if err != nil {
+		return
+	}
+	if isnull {
+		hi.nulls.remove(id)
+	} else if l := hi.m[k]; l != nil {
+		if l.remove(id); len(l.ids) == 0 {
+			delete(hi.m, k)
+		}
+	}
+}
+
+// IDs returns iterator over ids of rows with field value equal to v in ascending order,
+// v is converted to field type
+func (hi *HashIndex) IDs(v interface{}) (*ColumnIterator, error) {
+	k, isnull, err := hi.key(v)
+	if err != nil {
+		return nil, err
+	}
+	if isnull {
+		return NewColumnIterator(SortableList(nil), nil), nil
+	}
+	return NewColumnIterator(hi.m[k].share(), nil), nil
+}
+
+// NullIDs is IS NULL iterator
+func (hi *HashIndex) NullIDs() *ColumnIterator {
+	return NewColumnIterator(hi.nulls.share(), nil)
+}
+
+// Len returns number of distinct not NULL values
+func (hi *HashIndex) Len() int {
+	return len(hi.m)
+}
+
+// share returns ids that are not changed by further updates of l
+func (l *idList) share() SortableList {
+	if l == nil {
+		return nil
+	}
+	l.shared = true
+	return l.ids
+}
+
+// own copies ids shared with iterators before change
+func (l *idList) own(capacity int) {
+	if l.shared {
+		ids := make(SortableList, len(l.ids), capacity)
+		copy(ids, l.ids)
+		l.ids, l.shared = ids, false
+	}
+}
+
+func (l *idList) insert(id ModelSortable) {
+	ids := l.ids
+	i := sort.Search(len(ids), func(i int) bool { return !SortableLess(ids[i], id) })
+	if i < len(ids) && SortableEqual(ids[i], id) {
+		return
+	}
+	l.own(len(ids) + 1)
+	l.ids = insertItem(l.ids, i, id)
+}
+
+func (l *idList) remove(id ModelSortable) {
+	ids := l.ids
+	i := sort.Search(len(ids), func(i int) bool { return !SortableLess(ids[i], id) })
+	if i == len(ids) || !SortableEqual(ids[i], id) {
+		return
+	}
+	l.own(len(ids))
+	l.ids = removeItem(l.ids, i)
+}
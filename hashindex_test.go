@@ -0,0 +1,127 @@
+package inmemdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testExtID struct {
+	Source string
+	N      int
+}
+
+type TestHashMO struct {
+	ID      UUIDv4
+	Name    String
+	ExtID   testExtID
+	Payload []byte
+	Tag     interface{}
+}
+
+func (t TestHashMO) StoreName() string { return "testhashmo" }
+
+type failIndexer struct{ rows int }
+
+func (f *failIndexer) IndexRow(id ModelSortable, mo ModelObject) error {
+	if mo.Field(mo.md.ColumnByFieldName["Name"]) == String("fail") {
+		return ErrConstraint
+	}
+	f.rows++
+	return nil
+}
+
+func (f *failIndexer) UnindexRow(id ModelSortable, mo ModelObject) { f.rows-- }
+
+func TestHashIndex(t *testing.T) {
+	tbl, err := NewTable[TestHashMO](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := MustField[TestHashMO, []byte](tbl, "Payload").CreateHashIndex(); !errors.Is(err, ErrNotHashable) {
+		t.Errorf("expected ErrNotHashable, got %v", err)
+	}
+	ext := MustField[TestHashMO, testExtID](tbl, "ExtID")
+	if err := ext.CreateHashIndex(); err != nil {
+		t.Fatal(err)
+	}
+	name := MustField[TestHashMO, String](tbl, "Name")
+	if err := name.CreateHashIndex(); err != nil {
+		t.Fatal(err)
+	}
+	fi := &failIndexer{}
+	if err := tbl.ModelTable().AttachIndex(fi); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []UUIDv4{NewV4(), NewV4(), NewV4()}
+	for i, id := range ids {
+		if err := tbl.Insert(TestHashMO{ID: id, Name: "a", ExtID: testExtID{"crm", i % 2}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	it, err := ext.Eq(testExtID{"crm", 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collectIDs(it); len(got) != 2 || !SortableLess(got[0], got[1]) {
+		t.Errorf("wrong ids: %v", got)
+	}
+
+	if err := tbl.Insert(TestHashMO{ID: ids[0], Name: "fail", ExtID: testExtID{"crm", 5}}); !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected ErrConstraint, got %v", err)
+	}
+	if it, _ := ext.Eq(testExtID{"crm", 5}); it.Cardinality() != 0 || fi.rows != 3 {
+		t.Error("indexes must be rolled back")
+	}
+
+	if err := tbl.Insert(TestHashMO{ID: ids[0], Name: "b", ExtID: testExtID{"crm", 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Delete(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	it, _ = ext.Eq(testExtID{"crm", 1})
+	if got := collectIDs(it); len(got) != 1 || !got[0].ModelEqual(ids[0]) {
+		t.Errorf("wrong ids after update: %v", got)
+	}
+
+	byName, err := tbl.ModelTable().Where(name.FD(), OpEq, String("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byExt, _ := ext.Eq(testExtID{"crm", 0})
+	inter := NewIteratorIntersect()
+	inter.Append(byName)
+	inter.Append(byExt)
+	if got := collectIDs(inter); len(got) != 1 || !got[0].ModelEqual(ids[2]) {
+		t.Errorf("wrong intersection: %v", got)
+	}
+	if fi.rows != 2 || reflect.TypeOf(tbl.ModelTable().Indexers()[0]) != reflect.TypeOf(&HashIndex{}) {
+		t.Errorf("wrong indexers state: %d", fi.rows)
+	}
+
+	tag := MustField[TestHashMO, interface{}](tbl, "Tag")
+	if err := tag.CreateHashIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Insert(TestHashMO{ID: NewV4(), Tag: []int{1}}); !errors.Is(err, ErrNotHashable) {
+		t.Errorf("expected ErrNotHashable for slice value, got %v", err)
+	}
+	if err := tbl.Insert(TestHashMO{ID: NewV4(), Tag: "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// iterators keep ids while lists are changed in place
+	hi := tbl.ModelTable().HashIndex(tag.FD())
+	nulls := hi.NullIDs()
+	n := nulls.Cardinality()
+	for i := 0; i < 100; i++ {
+		if err := tbl.Insert(TestHashMO{ID: NewV4()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(collectIDs(nulls)) != n || hi.NullIDs().Cardinality() != n+100 {
+		t.Errorf("wrong NULL ids")
+	}
+}
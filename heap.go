@@ -7,6 +7,7 @@ type ElemHeapIDAcc struct {
 
 type IDAccHeap struct {
 	Elems []ElemHeapIDAcc
+	Desc  bool // max-heap for descending iterators
 }
 
 func NewIDAccHeap(capacity int) *IDAccHeap {
@@ -17,6 +18,7 @@ func NewIDAccHeap(capacity int) *IDAccHeap {
 
 func (h *IDAccHeap) Clone() *IDAccHeap {
 	rv := NewIDAccHeap(cap(h.Elems))
+	rv.Desc = h.Desc
 	for _, el := range h.Elems {
 		v := ElemHeapIDAcc{
 			ID:       el.ID,
@@ -29,6 +31,9 @@ func (h *IDAccHeap) Clone() *IDAccHeap {
 
 func (h *IDAccHeap) Len() int { return len(h.Elems) }
 func (h *IDAccHeap) Less(i, j int) bool {
+	if h.Desc {
+		return h.Elems[j].ID.ModelLess(h.Elems[i].ID)
+	}
 	return h.Elems[i].ID.ModelLess(h.Elems[j].ID)
 }
 func (h *IDAccHeap) Swap(i, j int) { h.Elems[i], h.Elems[j] = h.Elems[j], h.Elems[i] }
@@ -0,0 +1,167 @@
+package inmemdb
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// IndexBuild is a ModelIndex that is built in background, see ModelTable.BuildIndex
+type IndexBuild struct {
+	mt    *ModelTable
+	fd    *FieldDescription
+	start time.Time
+	mi    *ModelIndex
+	done  chan struct{}
+	log   []indexChange // changes of table after snapshot
+	ended error         // reason why Finish can't set index, after Finish or Cancel
+}
+
+type indexChange struct {
+	kv  KV
+	del bool
+}
+
+// BuildIndex starts online build of index of field fd. Keys of all rows are collected at once,
+// then they are sorted and loaded into index in background, while the table can be changed.
+// Changes of table are logged until Finish applies them to built index and sets it to table.
+// Keys of changed rows are checked like by existing index.
+// Like other methods of table, Finish and Cancel must not be called concurrently with its changes.
+// Field must not have index.
+func (mt *ModelTable) BuildIndex(fd *FieldDescription) (*IndexBuild, error) {
+	if mt.HasIndex(fd) {
+		return nil, errIndexExists(mt.md, fd)
+	}
+	start := time.Now()
+	kvs, err := mt.indexKVs(fd)
+	if err != nil {
+		return nil, err
+	}
+	b := &IndexBuild{
+		mt:    mt,
+		fd:    fd,
+		start: start,
+		done:  make(chan struct{}),
+	}
+	mt.indexers = append(mt.indexers, b)
+	go func() {
+		b.mi = newSortedIndex(kvs)
+		close(b.done)
+	}()
+	return b, nil
+}
+
+func (b *IndexBuild) FD() *FieldDescription {
+	return b.fd
+}
+
+// Done returns channel that is closed when background part of build is completed
+func (b *IndexBuild) Done() <-chan struct{} {
+	return b.done
+}
+
+func errIndexExists(md *ModelDescription, fd *FieldDescription) error {
+	return ErrorField{Type: md.ModelType, Field: fd.Name, Err: fmt.Errorf("%w: field already has index", ErrConstraint)}
+}
+
+// Finish waits for background build, applies logged changes and sets index to table.
+// It returns error after Cancel or Finish and if index of field was created during build.
+func (b *IndexBuild) Finish() (*ModelIndex, error) {
+	if b.ended != nil {
+		return nil, b.ended
+	}
+	<-b.done
+	b.mt.DetachIndex(b)
+	log := b.log
+	b.log, b.ended = nil, ErrorField{Type: b.mt.md.ModelType, Field: b.fd.Name, Err: fmt.Errorf("%w: index build is finished", ErrConstraint)}
+	if b.mt.HasIndex(b.fd) {
+		return nil, errIndexExists(b.mt.md, b.fd)
+	}
+	for _, c := range log {
+		if c.del {
+			b.mi.Delete(c.kv)
+		} else {
+			b.mi.Insert(c.kv)
+		}
+	}
+	b.mi.buildTime = time.Since(b.start)
+	b.mt.idxs[b.fd.Idx] = b.mi
+	return b.mi, nil
+}
+
+// Cancel stops logging of changes, built index is dropped
+func (b *IndexBuild) Cancel() {
+	b.mt.DetachIndex(b)
+	if b.ended == nil {
+		b.log, b.ended = nil, ErrorField{Type: b.mt.md.ModelType, Field: b.fd.Name, Err: fmt.Errorf("%w: index build is cancelled", ErrConstraint)}
+	}
+}
+
+// IndexRow is RowIndexer interface
+func (b *IndexBuild) IndexRow(id ModelSortable, mo ModelObject) error {
+	k, err := indexKey(b.mt.md, b.fd, mo.val(b.fd.Idx))
+	if err != nil {
+		return err
+	}
+	b.log = append(b.log, indexChange{kv: KV{K: k, V: id}})
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (b *IndexBuild) UnindexRow(id ModelSortable, mo ModelObject) {
+	if k, err := indexKey(b.mt.md, b.fd, mo.val(b.fd.Idx)); err == nil {
+		b.log = append(b.log, indexChange{kv: KV{K: k, V: id}, del: true})
+	}
+}
+
+// IndexStats describes ModelIndex
+type IndexStats struct {
+	Entries      int
+	DistinctKeys int
+	MemoryBytes  int64 // estimate of nodes, keys and ids
+	BuildTime    time.Duration
+}
+
+// IndexStats returns statistics of index of field fd, ok is false if there is no index
+func (mt *ModelTable) IndexStats(fd *FieldDescription) (stats IndexStats, ok bool) {
+	mi := mt.idxs[fd.Idx]
+	if mi == nil {
+		return stats, false
+	}
+	return mi.Stats(), true
+}
+
+// Stats returns statistics of index, it scans all entries
+func (mi *ModelIndex) Stats() IndexStats {
+	stats := IndexStats{
+		Entries:     mi.Len(),
+		MemoryBytes: mi.kvs.size(),
+		BuildTime:   mi.buildTime,
+	}
+	var prev ModelSortable
+	mi.kvs.Ascend(0, func(kv KV) bool {
+		if prev == nil || !SortableEqual(prev, kv.K) {
+			stats.DistinctKeys++
+		}
+		prev = kv.K
+		stats.MemoryBytes += sortableSize(kv.K) + sortableSize(kv.V)
+		return true
+	})
+	return stats
+}
+
+// sortableSize returns approximate memory referenced by interface value v
+func sortableSize(v ModelSortable) int64 {
+	if IsNull(v) {
+		return 0
+	}
+	rv := reflect.ValueOf(v)
+	sz := int64(rv.Type().Size())
+	switch rv.Kind() {
+	case reflect.String:
+		sz += int64(rv.Len())
+	case reflect.Slice:
+		sz += int64(rv.Cap()) * int64(rv.Type().Elem().Size())
+	}
+	return sz
+}
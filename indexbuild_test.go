@@ -0,0 +1,150 @@
+package inmemdb
+
+import (
+	"errors"
+	"testing"
+)
+
+type TestBuildMO struct {
+	ID   UUIDv4
+	Name String
+	Rank *String
+	Vec  Vector
+}
+
+func (t TestBuildMO) StoreName() string { return "testbuildmo" }
+
+func TestIndexBuild(t *testing.T) {
+	forEachStore(t, func(t *testing.T, tbl *Table[TestBuildMO]) {
+		mt := tbl.ModelTable()
+		name := MustField[TestBuildMO, String](tbl, "Name")
+		rank := MustField[TestBuildMO, *String](tbl, "Rank")
+		ids := make([]UUIDv4, 1000)
+		for i := range ids {
+			ids[i] = NewV4()
+			mo := TestBuildMO{ID: ids[i], Name: String([]string{"a", "b", "c"}[i%3])}
+			if i%2 == 0 {
+				r := String(rune('0' + i%10))
+				mo.Rank = &r
+			}
+			if err := tbl.Insert(mo); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, ok := name.IndexStats(); ok {
+			t.Fatal("unexpected stats of absent index")
+		}
+		if err := rank.CreateIndex(); err != nil {
+			t.Fatal(err)
+		}
+		stats, ok := rank.IndexStats()
+		if !ok || stats.Entries != 1000 || stats.DistinctKeys != 6 || stats.MemoryBytes <= 0 {
+			t.Fatalf("wrong stats %+v", stats)
+		}
+		if got := collectIDs(mt.idxs[rank.FD().Idx].IDs(Null)); len(got) != 500 {
+			t.Errorf("expected 500 NULL ranks, got %d", len(got))
+		}
+
+		b, err := name.BuildIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// changes during build
+		for i := 0; i < 100; i++ {
+			if err := tbl.Insert(TestBuildMO{ID: ids[i], Name: "z"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 100; i < 200; i++ {
+			if err := tbl.Delete(ids[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tbl.Insert(TestBuildMO{ID: NewV4(), Name: "a"}); err != nil {
+			t.Fatal(err)
+		}
+		<-b.Done()
+		if mt.HasIndex(name.FD()) {
+			t.Fatal("index must not be set before Finish")
+		}
+		mi, err := b.Finish()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mt.Indexers()) != 0 {
+			t.Fatal("build log is not detached")
+		}
+		if mi.Len() != 901 {
+			t.Fatalf("expected 901 entries, got %d", mi.Len())
+		}
+		for _, k := range []String{"a", "b", "c", "z"} {
+			scan, err := mt.Where(name.FD(), OpEq, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := collectIDs(scan)
+			got := collectIDs(mi.IDs(k))
+			if len(got) != len(want) {
+				t.Fatalf("%s: expected %d rows, got %d", k, len(want), len(got))
+			}
+			for i := range got {
+				if !got[i].ModelEqual(want[i]) {
+					t.Fatalf("%s: wrong rows", k)
+				}
+			}
+		}
+		if stats, _ := name.IndexStats(); stats.DistinctKeys != 4 || stats.BuildTime <= 0 {
+			t.Errorf("wrong stats %+v", stats)
+		}
+
+		if _, err := b.Finish(); !errors.Is(err, ErrConstraint) {
+			t.Errorf("expected ErrConstraint on second Finish, got %v", err)
+		}
+		if _, err := name.BuildIndex(); !errors.Is(err, ErrConstraint) {
+			t.Fatalf("expected ErrConstraint for existing index, got %v", err)
+		}
+
+		mt.DeleteIndex(name.FD())
+		b, err = name.BuildIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Cancel()
+		if len(mt.Indexers()) != 0 {
+			t.Fatal("build log is not detached on cancel")
+		}
+		if _, err := b.Finish(); !errors.Is(err, ErrConstraint) || mt.HasIndex(name.FD()) {
+			t.Fatalf("cancelled build must not set index, got %v", err)
+		}
+
+		// index created during build is not overwritten
+		b, err = name.BuildIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := name.CreateIndex(); err != nil {
+			t.Fatal(err)
+		}
+		mi = mt.idxs[name.FD().Idx]
+		if _, err := b.Finish(); !errors.Is(err, ErrConstraint) || mt.idxs[name.FD().Idx] != mi {
+			t.Fatalf("existing index must not be replaced, got %v", err)
+		}
+		if len(mt.Indexers()) != 0 {
+			t.Fatal("build log is not detached on failed Finish")
+		}
+	})
+}
+
+func TestIndexBuildError(t *testing.T) {
+	tbl, err := NewTable[TestBuildMO](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Insert(TestBuildMO{ID: NewV4(), Vec: Vector{1}}); err != nil {
+		t.Fatal(err)
+	}
+	vec := MustField[TestBuildMO, Vector](tbl, "Vec")
+	if _, err := vec.BuildIndex(); !errors.Is(err, ErrNotSortable) {
+		t.Fatalf("expected ErrNotSortable, got %v", err)
+	}
+}
@@ -0,0 +1,398 @@
+// Package inmemarrow converts inmemdb tables into Apache Arrow record batches
+// and reads/writes them in Arrow IPC file and stream formats.
+//
+// It is a separate module, so the core inmemdb package does not depend on Arrow.
+package inmemarrow
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/covrom/inmemdb"
+)
+
+const (
+	// DefaultBatchSize is the number of rows in record batch, if batch size is not set
+	DefaultBatchSize = 64 << 10
+
+	// MetadataStoreName is the schema metadata key with store name of model
+	MetadataStoreName = "inmemdb.store_name"
+	// MetadataEncoding is the field metadata key, "json" means that values
+	// without native Arrow type are stored as json strings
+	MetadataEncoding = "inmemdb.encoding"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(inmemdb.UUIDv4{})
+)
+
+// Schema returns Arrow schema of stored fields of model.
+// Field types are derived from FieldDescription.ElemType, slices of basic types become lists,
+// types without native Arrow representation are stored as json strings.
+// Times are stored as timestamps with microsecond precision.
+func Schema(md *inmemdb.ModelDescription) *arrow.Schema {
+	fds := storedFields(md)
+	fields := make([]arrow.Field, len(fds))
+	for i, fd := range fds {
+		dt, isJSON := arrowType(fd)
+		fields[i] = arrow.Field{Name: fd.Name, Type: dt, Nullable: fd.Nullable}
+		if isJSON {
+			fields[i].Metadata = arrow.NewMetadata([]string{MetadataEncoding}, []string{"json"})
+		}
+	}
+	meta := arrow.NewMetadata([]string{MetadataStoreName}, []string{md.StoreName})
+	return arrow.NewSchema(fields, &meta)
+}
+
+func storedFields(md *inmemdb.ModelDescription) []*inmemdb.FieldDescription {
+	res := make([]*inmemdb.FieldDescription, 0, len(md.ColumnPtrs))
+	for _, fd := range md.ColumnPtrs {
+		if fd.IsStored() {
+			res = append(res, fd)
+		}
+	}
+	return res
+}
+
+func arrowType(fd *inmemdb.FieldDescription) (arrow.DataType, bool) {
+	t, isList := fd.ElemType, fd.StructField.Type.Kind() == reflect.Slice
+	if !isList && t.Kind() == reflect.Slice {
+		// pointer to slice
+		t, isList = t.Elem(), true
+	}
+	if isList {
+		if t.Kind() == reflect.Uint8 {
+			return arrow.BinaryTypes.Binary, false
+		}
+		if dt := basicArrowType(t); dt != nil {
+			return arrow.ListOf(dt), false
+		}
+		return arrow.BinaryTypes.String, true
+	}
+	if dt := basicArrowType(t); dt != nil {
+		return dt, false
+	}
+	return arrow.BinaryTypes.String, true
+}
+
+func basicArrowType(t reflect.Type) arrow.DataType {
+	switch t {
+	case timeType:
+		// nanoseconds overflow outside of years 1678-2262, zero time is year 1
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case uuidType:
+		return &arrow.FixedSizeBinaryType{ByteWidth: 16}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return arrow.FixedWidthTypes.Boolean
+	case reflect.Int8:
+		return arrow.PrimitiveTypes.Int8
+	case reflect.Int16:
+		return arrow.PrimitiveTypes.Int16
+	case reflect.Int32:
+		return arrow.PrimitiveTypes.Int32
+	case reflect.Int, reflect.Int64:
+		return arrow.PrimitiveTypes.Int64
+	case reflect.Uint8:
+		return arrow.PrimitiveTypes.Uint8
+	case reflect.Uint16:
+		return arrow.PrimitiveTypes.Uint16
+	case reflect.Uint32:
+		return arrow.PrimitiveTypes.Uint32
+	case reflect.Uint, reflect.Uint64:
+		return arrow.PrimitiveTypes.Uint64
+	case reflect.Float32:
+		return arrow.PrimitiveTypes.Float32
+	case reflect.Float64:
+		return arrow.PrimitiveTypes.Float64
+	case reflect.String:
+		return arrow.BinaryTypes.String
+	}
+	return nil
+}
+
+// RecordBatches converts rows with ids from iter into record batches of batchSize rows,
+// all rows are converted if iter is nil. Absent and Null values are both converted to nulls.
+// Caller must release the batches.
+func RecordBatches(mem memory.Allocator, mt *inmemdb.ModelTable, iter inmemdb.IDIterator, batchSize int) ([]arrow.RecordBatch, error) {
+	var res []arrow.RecordBatch
+	err := writeRecordBatches(mem, mt, iter, batchSize, func(rec arrow.RecordBatch) error {
+		rec.Retain()
+		res = append(res, rec)
+		return nil
+	})
+	if err != nil {
+		for _, rec := range res {
+			rec.Release()
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func writeRecordBatches(mem memory.Allocator, mt *inmemdb.ModelTable, iter inmemdb.IDIterator, batchSize int,
+	write func(rec arrow.RecordBatch) error) error {
+	if iter == nil {
+		iter = inmemdb.NewColumnIterator(mt, nil)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if mem == nil {
+		mem = memory.DefaultAllocator
+	}
+
+	fds := storedFields(mt.MD())
+	jsons := make([]bool, len(fds))
+	for i, fd := range fds {
+		_, jsons[i] = arrowType(fd)
+	}
+	schema := Schema(mt.MD())
+	rb := array.NewRecordBuilder(mem, schema)
+	defer rb.Release()
+
+	flush := func() error {
+		rec := rb.NewRecordBatch()
+		defer rec.Release()
+		return write(rec)
+	}
+
+	n := 0
+	for iter.HasNext() {
+		mo, ok := mt.Get(iter.NextID())
+		if !ok {
+			continue
+		}
+		for i, fd := range fds {
+			if err := appendValue(rb.Field(i), mo.Field(fd), jsons[i]); err != nil {
+				return fmt.Errorf("can't convert field %s: %w", fd.Name, err)
+			}
+		}
+		n++
+		if n == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			n = 0
+		}
+	}
+	if n > 0 {
+		return flush()
+	}
+	return nil
+}
+
+func appendValue(b array.Builder, v interface{}, isJSON bool) error {
+	if inmemdb.IsNull(v) {
+		b.AppendNull()
+		return nil
+	}
+	if isJSON {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.(*array.StringBuilder).Append(string(data))
+		return nil
+	}
+	return appendReflect(b, reflect.Indirect(reflect.ValueOf(v)))
+}
+
+func appendReflect(b array.Builder, v reflect.Value) error {
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		b.Append(v.Bool())
+	case *array.Int8Builder:
+		b.Append(int8(v.Int()))
+	case *array.Int16Builder:
+		b.Append(int16(v.Int()))
+	case *array.Int32Builder:
+		b.Append(int32(v.Int()))
+	case *array.Int64Builder:
+		b.Append(v.Int())
+	case *array.Uint8Builder:
+		b.Append(uint8(v.Uint()))
+	case *array.Uint16Builder:
+		b.Append(uint16(v.Uint()))
+	case *array.Uint32Builder:
+		b.Append(uint32(v.Uint()))
+	case *array.Uint64Builder:
+		b.Append(v.Uint())
+	case *array.Float32Builder:
+		b.Append(float32(v.Float()))
+	case *array.Float64Builder:
+		b.Append(v.Float())
+	case *array.StringBuilder:
+		b.Append(v.String())
+	case *array.BinaryBuilder:
+		b.Append(v.Bytes())
+	case *array.FixedSizeBinaryBuilder:
+		u := v.Interface().(inmemdb.UUIDv4)
+		b.Append(u.UUID[:])
+	case *array.TimestampBuilder:
+		b.Append(arrow.Timestamp(v.Interface().(time.Time).UnixMicro()))
+	case *array.ListBuilder:
+		if v.IsNil() {
+			b.AppendNull()
+			return nil
+		}
+		b.Append(true)
+		vb := b.ValueBuilder()
+		for i := 0; i < v.Len(); i++ {
+			if err := appendReflect(vb, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported arrow builder %T", b)
+	}
+	return nil
+}
+
+// LoadRecordBatch upserts rows of record batch into the table, columns are matched by store name,
+// unknown columns are skipped and nulls become Null.
+// Invalid rows are skipped and returned as ErrorRows. Returns the number of loaded rows.
+func LoadRecordBatch(mt *inmemdb.ModelTable, rec arrow.RecordBatch) (int, error) {
+	var rowErrs inmemdb.ErrorRows
+	n := loadRecordBatch(mt, rec, 0, &rowErrs)
+	if len(rowErrs) > 0 {
+		return n, rowErrs
+	}
+	return n, nil
+}
+
+func loadRecordBatch(mt *inmemdb.ModelTable, rec arrow.RecordBatch, rowOffset int, rowErrs *inmemdb.ErrorRows) int {
+	md := mt.MD()
+	schema := rec.Schema()
+	fds := make([]*inmemdb.FieldDescription, schema.NumFields())
+	jsons := make([]bool, len(fds))
+	for i, f := range schema.Fields() {
+		if fd := md.ColumnByName[f.Name]; fd != nil && fd.IsStored() {
+			fds[i] = fd
+		}
+		if idx := f.Metadata.FindKey(MetadataEncoding); idx >= 0 {
+			jsons[i] = f.Metadata.Values()[idx] == "json"
+		}
+	}
+
+	n := 0
+	for row := 0; row < int(rec.NumRows()); row++ {
+		mo := inmemdb.NewModelObject(md)
+		var err error
+		for i, fd := range fds {
+			if fd == nil {
+				continue
+			}
+			var v interface{}
+			if v, err = columnValue(rec.Column(i), row, fd, jsons[i]); err != nil {
+				err = inmemdb.ErrorField{Type: md.ModelType, Field: fd.Name, Err: err}
+				break
+			}
+			if err = mo.SetField(fd, v); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = mo.Validate()
+		}
+		if err == nil {
+			err = mt.Upsert(mo)
+		}
+		if err != nil {
+			mo.Close()
+			*rowErrs = append(*rowErrs, inmemdb.ErrorRow{Row: rowOffset + row + 1, Err: err})
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func columnValue(arr arrow.Array, i int, fd *inmemdb.FieldDescription, isJSON bool) (interface{}, error) {
+	if arr.IsNull(i) {
+		return inmemdb.Null, nil
+	}
+	if isJSON {
+		s, ok := arr.(*array.String)
+		if !ok {
+			return nil, fmt.Errorf("json encoded column must be string, got %s", arr.DataType())
+		}
+		p := reflect.New(fd.StructField.Type)
+		if err := json.Unmarshal([]byte(s.Value(i)), p.Interface()); err != nil {
+			return nil, err
+		}
+		return p.Elem().Interface(), nil
+	}
+
+	if l, ok := arr.(*array.List); ok {
+		t := fd.StructField.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("can't convert list to %s", t)
+		}
+		start, end := l.ValueOffsets(i)
+		res := reflect.MakeSlice(t, int(end-start), int(end-start))
+		for j := start; j < end; j++ {
+			v, err := arrayValue(l.ListValues(), int(j))
+			if err != nil {
+				return nil, err
+			}
+			if v, err = inmemdb.ConvertToType(v, t.Elem()); err != nil {
+				return nil, err
+			}
+			res.Index(int(j - start)).Set(reflect.ValueOf(v))
+		}
+		return inmemdb.ConvertToType(res.Interface(), fd.StructField.Type)
+	}
+
+	v, err := arrayValue(arr, i)
+	if err != nil {
+		return nil, err
+	}
+	return inmemdb.ConvertToType(v, fd.StructField.Type)
+}
+
+func arrayValue(arr arrow.Array, i int) (interface{}, error) {
+	switch a := arr.(type) {
+	case *array.Boolean:
+		return a.Value(i), nil
+	case *array.Int8:
+		return a.Value(i), nil
+	case *array.Int16:
+		return a.Value(i), nil
+	case *array.Int32:
+		return a.Value(i), nil
+	case *array.Int64:
+		return a.Value(i), nil
+	case *array.Uint8:
+		return a.Value(i), nil
+	case *array.Uint16:
+		return a.Value(i), nil
+	case *array.Uint32:
+		return a.Value(i), nil
+	case *array.Uint64:
+		return a.Value(i), nil
+	case *array.Float32:
+		return a.Value(i), nil
+	case *array.Float64:
+		return a.Value(i), nil
+	case *array.String:
+		return a.Value(i), nil
+	case *array.Binary:
+		return append([]byte(nil), a.Value(i)...), nil
+	case *array.FixedSizeBinary:
+		return append([]byte(nil), a.Value(i)...), nil
+	case *array.Timestamp:
+		return a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit).UTC(), nil
+	}
+	return nil, fmt.Errorf("unsupported arrow type %s", arr.DataType())
+}
@@ -0,0 +1,120 @@
+package inmemarrow
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/covrom/inmemdb"
+)
+
+type testArrowMO struct {
+	ID      inmemdb.UUIDv4  `json:"id"`
+	Name    inmemdb.String  `json:"name"`
+	Note    *inmemdb.String `json:"note"`
+	Count   int             `json:"count"`
+	Tags    []string        `json:"tags"`
+	Created time.Time       `json:"created"`
+	Meta    map[string]int  `json:"meta"`
+}
+
+func (t testArrowMO) StoreName() string { return "testarrowmo" }
+
+func newTestTable(t *testing.T) *inmemdb.Table[testArrowMO] {
+	tbl, err := inmemdb.NewTable[testArrowMO](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := inmemdb.String("note")
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	rows := []testArrowMO{
+		{ID: inmemdb.NewV4(), Name: "a", Note: &note, Count: 1, Tags: []string{"x", "y"}, Created: created, Meta: map[string]int{"k": 1}},
+		// zero time and times out of range of nanosecond timestamps
+		{ID: inmemdb.NewV4(), Name: "b", Count: 2},
+		{ID: inmemdb.NewV4(), Name: "c", Count: 3, Tags: []string{}, Created: time.Date(1500, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, row := range rows {
+		if err := tbl.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tbl
+}
+
+func TestSchema(t *testing.T) {
+	tbl := newTestTable(t)
+	schema := Schema(tbl.MD())
+	if schema.NumFields() != 7 {
+		t.Fatalf("wrong schema: %s", schema)
+	}
+	f := schema.Field(2)
+	if f.Name != "note" || !f.Nullable || f.Type.ID() != arrow.STRING {
+		t.Errorf("wrong note field: %s", f)
+	}
+	if schema.Field(4).Type.ID() != arrow.LIST || schema.Field(1).Nullable {
+		t.Errorf("wrong fields: %s", schema)
+	}
+	if ts, ok := schema.Field(5).Type.(*arrow.TimestampType); !ok || ts.Unit != arrow.Microsecond {
+		t.Errorf("wrong time field: %s", schema.Field(5))
+	}
+	if idx := schema.Field(6).Metadata.FindKey(MetadataEncoding); idx < 0 {
+		t.Errorf("map must be json encoded: %s", schema.Field(6))
+	}
+
+	recs, err := RecordBatches(memory.DefaultAllocator, tbl.ModelTable(), nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, rec := range recs {
+			rec.Release()
+		}
+	}()
+	if len(recs) != 2 || recs[0].NumRows() != 2 || recs[1].NumRows() != 1 {
+		t.Fatalf("wrong batches: %v", recs)
+	}
+}
+
+func TestIPC(t *testing.T) {
+	tbl := newTestTable(t)
+	want, err := tbl.All().Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []bool{false, true} {
+		buf := &bytes.Buffer{}
+		if file {
+			err = WriteIPCFile(buf, tbl.ModelTable(), nil, 2)
+		} else {
+			err = WriteIPCStream(buf, tbl.ModelTable(), nil, 2)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tbl2, err := inmemdb.NewTable[testArrowMO](3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		if file {
+			n, err = ReadIPCFile(tbl2.ModelTable(), bytes.NewReader(buf.Bytes()))
+		} else {
+			n, err = ReadIPCStream(tbl2.ModelTable(), buf)
+		}
+		if err != nil || n != 3 {
+			t.Fatal(n, err)
+		}
+		got, err := tbl2.All().Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("file %v: roundtrip mismatch:\n%v\n%v", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package inmemarrow
+
+import (
+	"errors"
+	"io"
+
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/covrom/inmemdb"
+)
+
+// WriteIPCStream writes rows with ids from iter in Arrow IPC stream format,
+// all rows are written if iter is nil
+func WriteIPCStream(w io.Writer, mt *inmemdb.ModelTable, iter inmemdb.IDIterator, batchSize int) error {
+	iw := ipc.NewWriter(w, ipc.WithSchema(Schema(mt.MD())), ipc.WithAllocator(memory.DefaultAllocator))
+	if err := writeRecordBatches(memory.DefaultAllocator, mt, iter, batchSize, iw.Write); err != nil {
+		iw.Close()
+		return err
+	}
+	return iw.Close()
+}
+
+// WriteIPCFile writes rows with ids from iter in Arrow IPC file format,
+// all rows are written if iter is nil
+func WriteIPCFile(w io.Writer, mt *inmemdb.ModelTable, iter inmemdb.IDIterator, batchSize int) error {
+	fw, err := ipc.NewFileWriter(w, ipc.WithSchema(Schema(mt.MD())), ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		return err
+	}
+	if err := writeRecordBatches(memory.DefaultAllocator, mt, iter, batchSize, fw.Write); err != nil {
+		fw.Close()
+		return err
+	}
+	return fw.Close()
+}
+
+// ReadIPCStream upserts rows from Arrow IPC stream into the table.
+// Invalid rows are skipped and returned as ErrorRows, format errors stop the loading.
+// Returns the number of loaded rows.
+func ReadIPCStream(mt *inmemdb.ModelTable, r io.Reader) (int, error) {
+	ir, err := ipc.NewReader(r, ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		return 0, err
+	}
+	defer ir.Release()
+
+	var rowErrs inmemdb.ErrorRows
+	n, rows := 0, 0
+	for ir.Next() {
+		rec := ir.RecordBatch()
+		n += loadRecordBatch(mt, rec, rows, &rowErrs)
+		rows += int(rec.NumRows())
+	}
+	if err := ir.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return n, err
+	}
+	if len(rowErrs) > 0 {
+		return n, rowErrs
+	}
+	return n, nil
+}
+
+// ReadIPCFile is like ReadIPCStream, but reads Arrow IPC file
+func ReadIPCFile(mt *inmemdb.ModelTable, r ipc.ReadAtSeeker) (int, error) {
+	fr, err := ipc.NewFileReader(r, ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		return 0, err
+	}
+	defer fr.Close()
+
+	var rowErrs inmemdb.ErrorRows
+	n, rows := 0, 0
+	for i := 0; i < fr.NumRecords(); i++ {
+		rec, err := fr.RecordBatch(i)
+		if err != nil {
+			return n, err
+		}
+		n += loadRecordBatch(mt, rec, rows, &rowErrs)
+		rows += int(rec.NumRows())
+	}
+	if len(rowErrs) > 0 {
+		return n, rowErrs
+	}
+	return n, nil
+}
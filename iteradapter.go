@@ -0,0 +1,312 @@
+package inmemdb
+
+// LimitIterator returns first n ids of underlying iterator
+type LimitIterator struct {
+	it      IDIterator
+	n       int
+	taken   int           // ids returned by HasNext
+	last    ModelSortable // n-th id, it is found on first JumpTo
+	hasLast bool
+	done    bool
+}
+
+// Limit returns iterator over first n ids of it, it must not be read before
+func Limit(it IDIterator, n int) *LimitIterator {
+	return &LimitIterator{it: it, n: n}
+}
+
+func (iter *LimitIterator) Clone() IDIterator {
+	rv := &LimitIterator{}
+	*rv = *iter
+	rv.it = iter.it.Clone()
+	return rv
+}
+
+// Descending reports whether iterator walks in descending order
+func (iter *LimitIterator) Descending() bool {
+	return isDescending(iter.it)
+}
+
+// Reverse returns new iterator over the same ids in opposite order,
+// it reads first n ids of a clone of underlying iterator, so iter is not changed
+func (iter *LimitIterator) Reverse() IDIterator {
+	return Materialize(Limit(Reverse(Reverse(iter.it.Clone())), iter.n)).Reverse()
+}
+
+func (iter *LimitIterator) Cardinality() int {
+	if c := iter.it.Cardinality(); c < iter.n {
+		return c
+	}
+	return iter.n
+}
+
+// Range returns range of underlying iterator
+func (iter *LimitIterator) Range() (ModelSortable, ModelSortable) {
+	return iter.it.Range()
+}
+
+func (iter *LimitIterator) HasNext() bool {
+	if iter.done {
+		return false
+	}
+	if iter.hasLast {
+		iter.done = !iter.inLimit(iter.it.HasNext())
+		return !iter.done
+	}
+	if iter.taken >= iter.n || !iter.it.HasNext() {
+		iter.done = true
+		return false
+	}
+	iter.taken++
+	return true
+}
+
+// inLimit reports whether current id of underlying iterator is not after n-th id
+func (iter *LimitIterator) inLimit(ok bool) bool {
+	return ok && iter.last != nil && !idBefore(iter.last, iter.it.NextID(), iter.Descending())
+}
+
+// JumpTo moves to the first id after or equal to id in order of iteration, it must be within first n ids
+func (iter *LimitIterator) JumpTo(id ModelSortable) bool {
+	if !iter.hasLast {
+		c := iter.it.Clone()
+		var last ModelSortable
+		if iter.taken > 0 {
+			last = c.NextID()
+		}
+		for k := iter.taken; k < iter.n && c.HasNext(); k++ {
+			last = c.NextID()
+		}
+		iter.last, iter.hasLast = last, true
+	}
+	ok := iter.inLimit(iter.it.JumpTo(id))
+	iter.done = !ok
+	return ok
+}
+
+func (iter *LimitIterator) NextID() ModelSortable {
+	return iter.it.NextID()
+}
+
+// SkipIterator returns ids of underlying iterator except first n
+type SkipIterator struct {
+	it      IDIterator
+	n       int
+	skipped bool
+	first   ModelSortable // first id after skipped ones
+}
+
+// Skip returns iterator over ids of it except first n, it must not be read before
+func Skip(it IDIterator, n int) *SkipIterator {
+	return &SkipIterator{it: it, n: n}
+}
+
+func (iter *SkipIterator) Clone() IDIterator {
+	rv := &SkipIterator{}
+	*rv = *iter
+	rv.it = iter.it.Clone()
+	return rv
+}
+
+// Descending reports whether iterator walks in descending order
+func (iter *SkipIterator) Descending() bool {
+	return isDescending(iter.it)
+}
+
+// Reverse returns new iterator over the same ids in opposite order,
+// it reads first n+1 ids of a clone of underlying iterator, so iter is not changed
+func (iter *SkipIterator) Reverse() IDIterator {
+	// iterators without Reverse are read by Reverse, so it is called once and forward ids are taken from its result
+	rev := Reverse(iter.it.Clone())
+	fwd := Reverse(rev.Clone())
+	var first ModelSortable
+	for k := 0; k <= iter.n && fwd.HasNext(); k++ {
+		if k == iter.n {
+			first = fwd.NextID()
+		}
+	}
+	// reversed ids up to the first not skipped one
+	return &LimitIterator{it: rev, n: iter.Cardinality(), last: first, hasLast: true}
+}
+
+func (iter *SkipIterator) Cardinality() int {
+	if c := iter.it.Cardinality(); c > iter.n {
+		return c - iter.n
+	}
+	return 0
+}
+
+// Range returns range of underlying iterator
+func (iter *SkipIterator) Range() (ModelSortable, ModelSortable) {
+	return iter.it.Range()
+}
+
+func (iter *SkipIterator) HasNext() bool {
+	if iter.skipped {
+		return iter.it.HasNext()
+	}
+	iter.skipped = true
+	for k := 0; k <= iter.n; k++ {
+		if !iter.it.HasNext() {
+			return false
+		}
+	}
+	iter.first = iter.it.NextID()
+	return true
+}
+
+// JumpTo moves to the first id after or equal to id in order of iteration, skipped ids are never returned
+func (iter *SkipIterator) JumpTo(id ModelSortable) bool {
+	if !iter.skipped && !iter.HasNext() {
+		return false
+	}
+	if iter.first == nil {
+		return false
+	}
+	if idBefore(id, iter.first, iter.Descending()) {
+		id = iter.first
+	}
+	return iter.it.JumpTo(id)
+}
+
+func (iter *SkipIterator) NextID() ModelSortable {
+	return iter.it.NextID()
+}
+
+// FilterIterator returns ids of underlying iterator whose rows are matched by predicate
+type FilterIterator struct {
+	it   IDIterator
+	mt   *ModelTable
+	pred func(mo ModelObject) bool
+}
+
+// Filter returns iterator over ids of it whose rows in mt are matched by pred, ids without rows are skipped
+func Filter(it IDIterator, mt *ModelTable, pred func(mo ModelObject) bool) *FilterIterator {
+	return &FilterIterator{it: it, mt: mt, pred: pred}
+}
+
+func (iter *FilterIterator) Clone() IDIterator {
+	rv := &FilterIterator{}
+	*rv = *iter
+	rv.it = iter.it.Clone()
+	return rv
+}
+
+// Descending reports whether iterator walks in descending order
+func (iter *FilterIterator) Descending() bool {
+	return isDescending(iter.it)
+}
+
+// Reverse returns new iterator over the same ids in opposite order
+func (iter *FilterIterator) Reverse() IDIterator {
+	return Filter(Reverse(iter.it), iter.mt, iter.pred)
+}
+
+// Cardinality returns cardinality of underlying iterator as estimate
+func (iter *FilterIterator) Cardinality() int {
+	return iter.it.Cardinality()
+}
+
+// Range returns range of underlying iterator
+func (iter *FilterIterator) Range() (ModelSortable, ModelSortable) {
+	return iter.it.Range()
+}
+
+func (iter *FilterIterator) match() bool {
+	mo, ok := iter.mt.Get(iter.it.NextID())
+	return ok && iter.pred(mo)
+}
+
+func (iter *FilterIterator) HasNext() bool {
+	for iter.it.HasNext() {
+		if iter.match() {
+			return true
+		}
+	}
+	return false
+}
+
+func (iter *FilterIterator) JumpTo(id ModelSortable) bool {
+	if !iter.it.JumpTo(id) {
+		return false
+	}
+	return iter.match() || iter.HasNext()
+}
+
+func (iter *FilterIterator) NextID() ModelSortable {
+	return iter.it.NextID()
+}
+
+// Count reads it to the end and returns number of ids
+func Count(it IDIterator) int {
+	n := 0
+	for it.HasNext() {
+		n++
+	}
+	return n
+}
+
+// Collect reads it to the end and appends ids in order of iteration to buf[:0]
+func Collect(it IDIterator, buf SortableList) SortableList {
+	buf = buf[:0]
+	for it.HasNext() {
+		buf = append(buf, it.NextID())
+	}
+	return buf
+}
+
+// Materialize reads it to the end and returns iterator over read ids in the same order
+func Materialize(it IDIterator) *ColumnIterator {
+	ids := Collect(it, nil)
+	if !isDescending(it) {
+		return NewColumnIterator(ids, nil)
+	}
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	return NewReverseColumnIterator(ids, nil)
+}
+
+// RowIterator is an iterator over table rows, ids without rows are skipped
+type RowIterator struct {
+	it  IDIterator
+	mt  *ModelTable
+	id  ModelSortable
+	row ModelObject
+}
+
+// Rows returns iterator over rows of mt with ids from it
+func Rows(it IDIterator, mt *ModelTable) *RowIterator {
+	return &RowIterator{it: it, mt: mt}
+}
+
+func (iter *RowIterator) Clone() *RowIterator {
+	rv := &RowIterator{}
+	*rv = *iter
+	rv.it = iter.it.Clone()
+	return rv
+}
+
+func (iter *RowIterator) Next() bool {
+	for iter.it.HasNext() {
+		id := iter.it.NextID()
+		if mo, ok := iter.mt.Get(id); ok {
+			iter.id, iter.row = id, mo
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns error of underlying iterator, for example interrupted by context
+func (iter *RowIterator) Err() error {
+	return iterErr(iter.it)
+}
+
+func (iter *RowIterator) ID() ModelSortable {
+	return iter.id
+}
+
+func (iter *RowIterator) Row() ModelObject {
+	return iter.row
+}
@@ -0,0 +1,107 @@
+package inmemdb
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIteratorAdapters(t *testing.T) {
+	a := stringList("a", "b", "c", "d", "e", "f")
+	b := stringList("c", "d", "e", "x", "y")
+
+	checkIDs(t, "limit", Limit(NewColumnIterator(a, nil), 3), "a", "b", "c")
+	checkIDs(t, "skip", Skip(NewColumnIterator(a, nil), 4), "e", "f")
+	checkIDs(t, "skip all", Skip(NewColumnIterator(a, nil), 10))
+	checkIDs(t, "latest", Limit(NewReverseColumnIterator(a, nil), 2), "f", "e")
+	checkIDs(t, "page", Limit(Skip(NewColumnIterator(a, nil), 1), 2), "b", "c")
+
+	// JumpTo inside intersection respects limits
+	inter := NewIteratorIntersect()
+	inter.Append(Limit(NewColumnIterator(a, nil), 4))
+	inter.Append(Skip(NewColumnIterator(b, nil), 1))
+	checkIDs(t, "intersect", inter.Clone(), "d")
+
+	merge := NewMergeIterator(Limit(NewColumnIterator(a, nil), 2), Skip(NewColumnIterator(b, nil), 3))
+	checkIDs(t, "merge", merge.Clone(), "a", "b", "x", "y")
+	if !merge.JumpTo(String("c")) || !merge.NextID().ModelEqual(String("x")) {
+		t.Error("wrong JumpTo of merged adapters")
+	}
+
+	lim := Limit(NewColumnIterator(a, nil), 3)
+	lim.HasNext()
+	clone := lim.Clone()
+	checkIDs(t, "limit rest", lim, "b", "c")
+	checkIDs(t, "limit clone", clone, "b", "c")
+	if Count(Limit(NewColumnIterator(a, nil), 3)) != 3 {
+		t.Error("wrong count")
+	}
+
+	buf := make(SortableList, 0, 10)
+	ids := Collect(Skip(NewColumnIterator(b, nil), 3), buf)
+	if len(ids) != 2 || &ids[0] != &buf[:1][0] {
+		t.Errorf("buffer is not reused: %v", ids)
+	}
+	checkIDs(t, "materialized", Materialize(Limit(NewReverseColumnIterator(a, nil), 3)), "f", "e", "d")
+	checkIDs(t, "reversed adapter", Reverse(Limit(NewReverseColumnIterator(a, nil), 3)), "d", "e", "f")
+	skip := Skip(NewColumnIterator(a, nil), 2)
+	skip.HasNext()
+	checkIDs(t, "reversed skip", Reverse(skip), "f", "e", "d", "c")
+	checkIDs(t, "reversed skip all", Reverse(Skip(NewColumnIterator(a, nil), 10)))
+	ctxIt := WithContext(context.Background(), Limit(NewColumnIterator(b, nil), 2))
+	checkIDs(t, "reversed context", Reverse(ctxIt), "d", "c")
+
+	// underlying iterators without Reverse are read once and not changed
+	skip = Skip(forwardIterator{NewColumnIterator(a, nil)}, 2)
+	checkIDs(t, "reversed forward skip", Reverse(skip), "f", "e", "d", "c")
+	checkIDs(t, "forward skip", skip, "c", "d", "e", "f")
+	lim = Limit(forwardIterator{NewColumnIterator(a, nil)}, 2)
+	checkIDs(t, "reversed forward limit", Reverse(lim), "b", "a")
+	checkIDs(t, "forward limit", lim, "a", "b")
+}
+
+// forwardIterator hides Reverse and Descending methods of underlying iterator
+type forwardIterator struct {
+	IDIterator
+}
+
+func (iter forwardIterator) Clone() IDIterator {
+	return forwardIterator{iter.IDIterator.Clone()}
+}
+
+func TestFilterRows(t *testing.T) {
+	tt := TestMO{}
+	md, _ := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	namefd, _ := md.GetColumnByFieldName("Name")
+	mt := NewModelTable(md, 10)
+	for _, name := range []string{"apple", "banana", "avocado", "cherry"} {
+		mo := NewModelObject(md)
+		mo.SetIDField(NewV4())
+		mo.SetField(namefd, name)
+		if err := mt.Upsert(mo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	startsWithA := func(mo ModelObject) bool {
+		return strings.HasPrefix(string(mo.Field(namefd).(String)), "a")
+	}
+	all := NewColumnIterator(mt, nil)
+	if n := Count(Filter(all.Clone(), mt, startsWithA)); n != 2 {
+		t.Fatalf("expected 2 rows, got %d", n)
+	}
+	if n := Count(Reverse(Filter(all.Clone(), mt, startsWithA))); n != 2 {
+		t.Fatalf("expected 2 reversed rows, got %d", n)
+	}
+	rows := Rows(Limit(Filter(all, mt, startsWithA), 1), mt)
+	n := 0
+	for rows.Next() {
+		if !startsWithA(rows.Row()) || !rows.ID().ModelEqual(rows.Row().IDField().(ModelSortable)) {
+			t.Errorf("wrong row %v", rows.Row())
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("expected 1 row, got %d", n)
+	}
+}
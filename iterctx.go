@@ -0,0 +1,146 @@
+package inmemdb
+
+import (
+	"context"
+	"fmt"
+)
+
+// contextCheckSteps is a number of iteration steps between checks of context
+const contextCheckSteps = 256
+
+// ContextIterator stops iteration when context is done, see WithContext
+type ContextIterator struct {
+	it IDIterator
+	st *contextState
+}
+
+// contextState is shared by all wrappers of one iterator tree
+type contextState struct {
+	ctx   context.Context
+	steps int
+	err   error
+}
+
+func (st *contextState) check() bool {
+	if st.err != nil {
+		return false
+	}
+	st.steps++
+	if st.steps%contextCheckSteps == 1 {
+		if err := st.ctx.Err(); err != nil {
+			st.err = fmt.Errorf("%w: iteration is interrupted after %d steps", err, st.steps)
+		}
+	}
+	return st.err == nil
+}
+
+// WithContext returns iterator over ids of it that stops when ctx is done, then Err returns the cause.
+// Children of intersect, merge and adapter iterators are wrapped too, so long inner walks are interrupted.
+// It must not be used after wrapping.
+func WithContext(ctx context.Context, it IDIterator) *ContextIterator {
+	st := &contextState{ctx: ctx}
+	return &ContextIterator{it: wrapContext(it, st), st: st}
+}
+
+func wrapContext(it IDIterator, st *contextState) IDIterator {
+	wrap := func(it IDIterator) IDIterator {
+		if it == nil {
+			return nil
+		}
+		return &ContextIterator{it: wrapContext(it, st), st: st}
+	}
+	switch v := it.(type) {
+	case *IntersectIterator:
+		rv := *v
+		rv.iterators = make([]IDIterator, len(v.iterators))
+		for i, c := range v.iterators {
+			rv.iterators[i] = wrap(c)
+		}
+		rv.iterdiffs = make([]IDIterator, len(v.iterdiffs))
+		for i, c := range v.iterdiffs {
+			rv.iterdiffs[i] = wrap(c)
+		}
+		return &rv
+	case *MergeIterator:
+		// heap elements refer to the same iterators
+		wrapped := make(map[IDIterator]IDIterator, len(v.iterators))
+		rv := *v
+		rv.iterators = make([]IDIterator, len(v.iterators))
+		for i, c := range v.iterators {
+			rv.iterators[i] = wrap(c)
+			if c != nil {
+				wrapped[c] = rv.iterators[i]
+			}
+		}
+		rv.minheap = &IDAccHeap{Elems: make([]ElemHeapIDAcc, len(v.minheap.Elems)), Desc: v.minheap.Desc}
+		for i, e := range v.minheap.Elems {
+			w, ok := wrapped[e.Iterator]
+			if !ok {
+				w = wrap(e.Iterator)
+			}
+			rv.minheap.Elems[i] = ElemHeapIDAcc{ID: e.ID, Iterator: w}
+		}
+		return &rv
+	case *LimitIterator:
+		rv := *v
+		rv.it = wrap(v.it)
+		return &rv
+	case *SkipIterator:
+		rv := *v
+		rv.it = wrap(v.it)
+		return &rv
+	case *FilterIterator:
+		rv := *v
+		rv.it = wrap(v.it)
+		return &rv
+	}
+	return it
+}
+
+// iterErr returns error of iterator that has Err method like ContextIterator
+func iterErr(it IDIterator) error {
+	if e, ok := it.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+// Err returns error of context if iteration is interrupted
+func (iter *ContextIterator) Err() error {
+	return iter.st.err
+}
+
+// Clone returns iterator that shares context and error with iter
+func (iter *ContextIterator) Clone() IDIterator {
+	return &ContextIterator{it: iter.it.Clone(), st: iter.st}
+}
+
+// Descending reports whether iterator walks in descending order
+func (iter *ContextIterator) Descending() bool {
+	return isDescending(iter.it)
+}
+
+// Reverse returns iterator over the same ids in opposite order that shares context and error with iter
+func (iter *ContextIterator) Reverse() IDIterator {
+	return &ContextIterator{it: Reverse(iter.it), st: iter.st}
+}
+
+func (iter *ContextIterator) Cardinality() int {
+	return iter.it.Cardinality()
+}
+
+func (iter *ContextIterator) Range() (ModelSortable, ModelSortable) {
+	return iter.it.Range()
+}
+
+func (iter *ContextIterator) HasNext() bool {
+	return iter.st.check() && iter.it.HasNext()
+}
+
+func (iter *ContextIterator) JumpTo(id ModelSortable) bool {
+	return iter.st.check() && iter.it.JumpTo(id)
+}
+
+func (iter *ContextIterator) NextID() ModelSortable {
+	return iter.it.NextID()
+}
@@ -0,0 +1,79 @@
+package inmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestContextIterator(t *testing.T) {
+	var evens, odds SortableList
+	for i := 0; i < 100000; i++ {
+		s := String(fmt.Sprintf("%06d", i))
+		if i%2 == 0 {
+			evens = append(evens, s)
+		} else {
+			odds = append(odds, s)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	it := WithContext(ctx, NewColumnIterator(evens, nil))
+	if it.HasNext() || !errors.Is(it.Err(), context.Canceled) {
+		t.Fatalf("canceled iterator must stop, err %v", it.Err())
+	}
+
+	// disjoint intersection is walked inside of one HasNext
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	steps := 0
+	cancelAt := func(id ModelSortable) bool {
+		if steps++; steps == 1000 {
+			cancel()
+		}
+		return false
+	}
+	inter := NewIteratorIntersect()
+	inter.Append(NewColumnIterator(evens, cancelAt))
+	inter.Append(NewMergeIterator(NewColumnIterator(odds, nil)))
+	it = WithContext(ctx, inter)
+	if it.HasNext() {
+		t.Fatal("unexpected id in disjoint intersection")
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Fatalf("expected canceled error, got %v", it.Err())
+	}
+	if steps > 1000+2*contextCheckSteps {
+		t.Errorf("walk is not interrupted, %d steps", steps)
+	}
+	if it.Clone().HasNext() {
+		t.Error("clone must share interruption")
+	}
+
+	// completed walk has no error
+	merge := NewMergeIterator(NewColumnIterator(evens[:10], nil), NewColumnIterator(odds[:10], nil))
+	it = WithContext(context.Background(), merge)
+	if n := Count(it); n != 20 || it.Err() != nil {
+		t.Errorf("expected 20 ids without error, got %d %v", n, it.Err())
+	}
+}
+
+func TestTableIterContext(t *testing.T) {
+	tbl, err := NewTable[TestMO](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := tbl.Insert(TestMO{ID: NewV4(), Name: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rows, err := tbl.Iter(WithContext(ctx, NewColumnIterator(tbl.ModelTable(), nil))).Collect()
+	if len(rows) != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled error, got %d rows and %v", len(rows), err)
+	}
+}
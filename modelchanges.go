@@ -0,0 +1,143 @@
+package inmemdb
+
+import (
+	"reflect"
+	"time"
+)
+
+// FieldChange is a change of field value since the last Snapshot
+type FieldChange struct {
+	FD  *FieldDescription
+	Old interface{}
+	New interface{}
+}
+
+// Snapshot saves current values as original ones, changes are tracked from this point
+func (mo *ModelObject) Snapshot() {
+	mo.Load()
+	if mo.orig == nil {
+		mo.orig = getValSlice(len(mo.v))
+	}
+	copy(mo.orig, mo.v)
+}
+
+// DropSnapshot stops change tracking, all non-nil fields become dirty
+func (mo *ModelObject) DropSnapshot() {
+	putValSlice(mo.orig)
+	mo.orig = nil
+}
+
+func (mo ModelObject) HasSnapshot() bool {
+	return mo.orig != nil
+}
+
+// Rollback restores values from the last Snapshot
+func (mo ModelObject) Rollback() {
+	mo.Load()
+	if mo.orig == nil {
+		return
+	}
+	copy(mo.v, mo.orig)
+}
+
+// Original returns value of field at the last Snapshot
+func (mo ModelObject) Original(fd *FieldDescription) interface{} {
+	if mo.orig == nil {
+		return nil
+	}
+	return mo.orig[fd.Idx]
+}
+
+func (mo ModelObject) isDirty(i int) bool {
+	if mo.orig == nil {
+		return mo.v[i] != nil
+	}
+	return !valuesEqual(mo.orig[i], mo.v[i])
+}
+
+// IsDirty reports whether any field was changed since the last Snapshot,
+// without snapshot all non-nil fields are treated as changed
+func (mo ModelObject) IsDirty() bool {
+	mo.Load()
+	for i := range mo.v {
+		if mo.isDirty(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dirty returns fields changed since the last Snapshot
+func (mo ModelObject) Dirty() []*FieldDescription {
+	mo.Load()
+	var res []*FieldDescription
+	for i := range mo.v {
+		if mo.isDirty(i) {
+			res = append(res, mo.md.ColumnPtrs[i])
+		}
+	}
+	return res
+}
+
+// Changes returns fields changed since the last Snapshot with old and new values,
+// nil value means the absent field, Null - NULL value
+func (mo ModelObject) Changes() []FieldChange {
+	mo.Load()
+	var res []FieldChange
+	for i, v := range mo.v {
+		if !mo.isDirty(i) {
+			continue
+		}
+		ch := FieldChange{
+			FD:  mo.md.ColumnPtrs[i],
+			New: v,
+		}
+		if mo.orig != nil {
+			ch.Old = mo.orig[i]
+		}
+		res = append(res, ch)
+	}
+	return res
+}
+
+// DirtyDBData is DBData for changed stored fields only, it is used for UPDATE ... SET statements,
+// removed fields are returned with Null value
+func (mo ModelObject) DirtyDBData() (cols []string, vals []interface{}) {
+	mo.Load()
+	for fdi, v := range mo.v {
+		fd := mo.md.ColumnPtrs[fdi]
+		if !fd.IsStored() || !mo.isDirty(fdi) {
+			continue
+		}
+		if v == nil {
+			v = Null
+		}
+		cols = append(cols, fd.Name)
+		vals = append(vals, v)
+	}
+	return
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if av, bv := reflect.ValueOf(a), reflect.ValueOf(b); av.Kind() == reflect.Ptr {
+		if av.IsNil() || bv.IsNil() {
+			return av.IsNil() && bv.IsNil()
+		}
+		return valuesEqual(av.Elem().Interface(), bv.Elem().Interface())
+	}
+	switch av := a.(type) {
+	case NullType:
+		return true
+	case ModelSortable:
+		return av.ModelEqual(b.(ModelSortable))
+	case time.Time:
+		return av.Equal(b.(time.Time))
+	}
+	return reflect.DeepEqual(a, b)
+}
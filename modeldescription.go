@@ -65,22 +65,31 @@ func (md ModelDescription) GetUniqName() string {
 func (md ModelDescription) GetColumnByFieldName(fieldName string) (*FieldDescription, error) {
 	field, ok := md.ColumnByFieldName[fieldName]
 	if !ok {
-		return nil, fmt.Errorf("no such field: %s.%s", md.ModelType.Name(), fieldName)
+		return nil, ErrorField{Type: md.ModelType, Field: fieldName, Err: ErrUnknownField}
 	}
 	return field, nil
 }
 
-func (md ModelDescription) GetColumnsByFieldNames(fieldNames ...string) (res []*FieldDescription) {
+func (md ModelDescription) GetColumnsByFieldNames(fieldNames ...string) (res []*FieldDescription, err error) {
 	for _, fieldName := range fieldNames {
-		field, ok := md.ColumnByFieldName[fieldName]
-		if !ok {
-			panic(fmt.Sprintf("no such field: %s.%s", md.ModelType.Name(), fieldName))
+		field, err := md.GetColumnByFieldName(fieldName)
+		if err != nil {
+			return nil, err
 		}
 		res = append(res, field)
 	}
 	return
 }
 
+// MustGetColumnsByFieldNames is like GetColumnsByFieldNames but panics on unknown field
+func (md ModelDescription) MustGetColumnsByFieldNames(fieldNames ...string) []*FieldDescription {
+	res, err := md.GetColumnsByFieldNames(fieldNames...)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
 func (md ModelDescription) GetStoredColumnNames() (res []string) {
 	cols := make([]string, 0, len(md.Columns))
 	for _, fd := range md.ColumnPtrs {
@@ -95,7 +104,7 @@ func (md ModelDescription) GetStoredColumnNames() (res []string) {
 func (md ModelDescription) GetColumnByJsonName(jsonName string) (*FieldDescription, error) {
 	field, ok := md.ColumnByJsonName[jsonName]
 	if !ok {
-		return nil, fmt.Errorf("no such field: %s.%s", md.ModelType.Name(), jsonName)
+		return nil, ErrorField{Type: md.ModelType, Field: jsonName, Err: ErrUnknownField}
 	}
 	return field, nil
 }
@@ -3,6 +3,7 @@ package inmemdb
 import "sort"
 
 type IntersectIterator struct {
+	desc         bool
 	iterators    []IDIterator
 	iterdiffs    []IDIterator
 	currid       ModelSortable
@@ -18,16 +19,60 @@ func NewIteratorIntersect() *IntersectIterator {
 	}
 }
 
+// NewReverseIteratorIntersect returns intersection of iterators in descending order,
+// appended ascending iterators are reversed
+func NewReverseIteratorIntersect() *IntersectIterator {
+	iter := NewIteratorIntersect()
+	iter.desc = true
+	return iter
+}
+
+// Descending reports whether iterator walks in descending order
+func (iter *IntersectIterator) Descending() bool {
+	return iter.desc
+}
+
+// Reverse returns intersection of reversed iterators
+func (iter *IntersectIterator) Reverse() IDIterator {
+	rv := NewIteratorIntersect()
+	rv.desc = !iter.desc
+	for _, it := range iter.iterators {
+		rv.Append(Reverse(it))
+	}
+	for _, it := range iter.iterdiffs {
+		rv.AppendDiff(Reverse(it))
+	}
+	return rv
+}
+
+// before reports whether a is before b in order of iteration
+func (iter *IntersectIterator) before(a, b ModelSortable) bool {
+	return idBefore(a, b, iter.desc)
+}
+
 func (iter *IntersectIterator) Clone() IDIterator {
 	rv := &IntersectIterator{}
 	*rv = *iter
+	rv.iterators = cloneIterators(iter.iterators)
+	rv.iterdiffs = cloneIterators(iter.iterdiffs)
 	return rv
 }
 
+func cloneIterators(its []IDIterator) []IDIterator {
+	res := make([]IDIterator, len(its), cap(its))
+	for i, it := range its {
+		res[i] = it.Clone()
+	}
+	return res
+}
+
 func (iter *IntersectIterator) Append(iterator IDIterator) {
 	if iterator == nil {
 		return
 	}
+	if isDescending(iterator) != iter.desc {
+		iterator = Reverse(iterator)
+	}
 	ln := len(iter.iterators)
 	idx := sort.Search(ln, func(i int) bool {
 		return iter.iterators[i].Cardinality() >= iterator.Cardinality()
@@ -66,6 +111,9 @@ func (iter *IntersectIterator) AppendDiff(iterator IDIterator) {
 	if iterator == nil {
 		return
 	}
+	if isDescending(iterator) != iter.desc {
+		iterator = Reverse(iterator)
+	}
 	ln := len(iter.iterdiffs)
 	idx := sort.Search(ln, func(i int) bool {
 		return iter.iterdiffs[i].Cardinality() <= iterator.Cardinality()
@@ -180,14 +228,14 @@ retry:
 		if v.ModelEqual(cmpID) {
 			iidx++
 		} else {
-			if cmpID.ModelLess(v) {
+			if iter.before(cmpID, v) {
 				if !it0.JumpTo(v) {
 					return false
 				}
 				cmpID = it0.NextID()
 				iidx = 1
 			} else {
-				// v < cmpID
+				// v is before cmpID
 				if !it.JumpTo(cmpID) {
 					return false
 				}
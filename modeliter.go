@@ -6,6 +6,16 @@ type IterColumner interface {
 	Len() int
 }
 
+// SortableList is IterColumner over already sorted values
+type SortableList []ModelSortable
+
+func (l SortableList) Key(i int) ModelSortable { return l[i] }
+func (l SortableList) Len() int                { return len(l) }
+
+// sort.Interface
+func (l SortableList) Less(i, j int) bool { return SortableLess(l[i], l[j]) }
+func (l SortableList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
 type IDIterator interface {
 	HasNext() bool
 	NextID() ModelSortable
@@ -15,6 +25,29 @@ type IDIterator interface {
 	Clone() IDIterator
 }
 
+// isDescending reports whether it walks in descending order
+func isDescending(it IDIterator) bool {
+	d, ok := it.(interface{ Descending() bool })
+	return ok && d.Descending()
+}
+
+// idBefore reports whether a is before b in ascending or descending order
+func idBefore(a, b ModelSortable, desc bool) bool {
+	if desc {
+		return b.ModelLess(a)
+	}
+	return a.ModelLess(b)
+}
+
+// Reverse returns new iterator over ids of it in opposite order,
+// iterators without Reverse method are read to the end by Materialize
+func Reverse(it IDIterator) IDIterator {
+	if r, ok := it.(interface{ Reverse() IDIterator }); ok {
+		return r.Reverse()
+	}
+	return Materialize(it).Reverse()
+}
+
 func NewColumnIterator(c IterColumner, filterSkip func(idx ModelSortable) bool) *ColumnIterator {
 	return &ColumnIterator{
 		pos:        -1,
@@ -25,7 +58,16 @@ func NewColumnIterator(c IterColumner, filterSkip func(idx ModelSortable) bool)
 	}
 }
 
+// NewReverseColumnIterator returns iterator over c in descending order
+func NewReverseColumnIterator(c IterColumner, filterSkip func(idx ModelSortable) bool) *ColumnIterator {
+	iter := NewColumnIterator(c, filterSkip)
+	iter.pos = iter.maxpos + 1
+	iter.desc = true
+	return iter
+}
+
 type ColumnIterator struct {
+	desc       bool
 	pos        int
 	minpos     int
 	maxpos     int
@@ -41,29 +83,51 @@ func (iter *ColumnIterator) Clone() IDIterator {
 	return rv
 }
 
+// Descending reports whether iterator walks in descending order
+func (iter *ColumnIterator) Descending() bool {
+	return iter.desc
+}
+
+// Reverse returns new iterator over the same column in opposite order
+func (iter *ColumnIterator) Reverse() IDIterator {
+	if iter.desc {
+		return NewColumnIterator(iter.col, iter.filterSkip)
+	}
+	return NewReverseColumnIterator(iter.col, iter.filterSkip)
+}
+
 func (iter *ColumnIterator) Cardinality() int {
 	return iter.maxpos - iter.minpos + 1
 }
 
 func (iter *ColumnIterator) Range() (ModelSortable, ModelSortable) {
+	if iter.maxpos < iter.minpos {
+		return nil, nil
+	}
 	a, b := iter.col.Key(iter.minpos), iter.col.Key(iter.maxpos)
-	if b.ModelLess(a) {
+	if SortableLess(b, a) {
 		a, b = b, a
 	}
 	return a, b
 }
 
+// JumpTo moves to the first id that is not less than id,
+// descending iterator moves to the first id that is not greater than id
 func (iter *ColumnIterator) JumpTo(id ModelSortable) bool {
-	if iter.lastJumpTo.ModelEqual(id) {
+	if iter.lastJumpTo != nil && SortableEqual(iter.lastJumpTo, id) {
 		return iter.lastJumpOk
 	}
 	iter.lastJumpTo = id
+	if iter.desc {
+		return iter.jumpToDesc(id)
+	}
 	newpos := id
-	if newpos.ModelLess(iter.col.Key(iter.minpos)) || iter.col.Key(iter.maxpos).ModelLess(newpos) {
+	if iter.maxpos < iter.minpos ||
+		SortableLess(newpos, iter.col.Key(iter.minpos)) || SortableLess(iter.col.Key(iter.maxpos), newpos) {
 		iter.lastJumpOk = false
 		return false
 	}
-	if iter.col.Key(iter.pos).ModelEqual(newpos) {
+	if iter.pos >= iter.minpos && SortableEqual(iter.col.Key(iter.pos), newpos) {
 		iter.lastJumpOk = true
 		return true
 	}
@@ -73,7 +137,7 @@ func (iter *ColumnIterator) JumpTo(id ModelSortable) bool {
 
 	for i < j {
 		h := (i + j) >> 1
-		if iter.col.Key(h).ModelLess(id) {
+		if SortableLess(iter.col.Key(h), id) {
 			i = h + 1
 		} else {
 			j = h
@@ -85,14 +149,42 @@ func (iter *ColumnIterator) JumpTo(id ModelSortable) bool {
 	return iter.lastJumpOk
 }
 
+func (iter *ColumnIterator) jumpToDesc(id ModelSortable) bool {
+	if iter.maxpos < iter.minpos || SortableLess(id, iter.col.Key(iter.minpos)) {
+		iter.lastJumpOk = false
+		return false
+	}
+	if iter.pos <= iter.maxpos && iter.pos >= iter.minpos && SortableEqual(iter.col.Key(iter.pos), id) {
+		iter.lastJumpOk = true
+		return true
+	}
+	// first position with key greater than id
+	i, j := iter.minpos, iter.maxpos+1
+	for i < j {
+		h := (i + j) >> 1
+		if SortableLess(id, iter.col.Key(h)) {
+			j = h
+		} else {
+			i = h + 1
+		}
+	}
+	iter.pos = i
+	iter.lastJumpOk = iter.HasNext()
+	return iter.lastJumpOk
+}
+
 func (iter *ColumnIterator) HasNext() bool {
 	ipos, imin, imax := iter.pos, iter.minpos, iter.maxpos
-	ipos++
+	step := 1
+	if iter.desc {
+		step = -1
+	}
+	ipos += step
 	var ikey ModelSortable
 	if ipos >= imin && ipos <= imax {
 		ikey = iter.col.Key(ipos)
 		for iter.filterSkip != nil && iter.filterSkip(ikey) {
-			ipos++
+			ipos += step
 			if ipos < imin || ipos > imax {
 				break
 			}
@@ -40,9 +40,166 @@ func TestColumnIterator(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mi := mt.CreateIndex(namefd)
+	mi, err := mt.CreateIndex(namefd)
+	if err != nil {
+		t.Fatal(err)
+	}
 	coliter := NewColumnIterator(mi, nil)
 	for coliter.HasNext() {
 		t.Log(coliter.NextID())
 	}
 }
+
+type TestNullMO struct {
+	ID   UUIDv4
+	Note *String
+}
+
+func (t TestNullMO) StoreName() string { return "testnullmo" }
+
+func TestNullIndex(t *testing.T) {
+	tt := TestNullMO{}
+	md, _ := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	notefd, _ := md.GetColumnByFieldName("Note")
+
+	mt := NewModelTable(md, 10)
+	notes := []interface{}{"b", nil, "a", Null, "b"}
+	for _, note := range notes {
+		mo := NewModelObject(md)
+		mo.SetIDField(NewV4())
+		if note != nil {
+			if err := mo.SetField(notefd, note); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := mt.Upsert(mo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mi := mt.MustCreateIndex(notefd)
+	if !IsNull(mi.Key(0)) || !IsNull(mi.Key(1)) || IsNull(mi.Key(2)) {
+		t.Fatal("nulls must be first in index")
+	}
+
+	count := func(iter IDIterator, errs ...error) int {
+		for _, err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		n := 0
+		var prev ModelSortable
+		for iter.HasNext() {
+			id := iter.NextID()
+			if prev != nil && !prev.ModelLess(id) {
+				t.Fatal("ids must be in ascending order")
+			}
+			prev = id
+			n++
+		}
+		return n
+	}
+
+	if n := count(mt.IsNull(notefd)); n != 2 {
+		t.Errorf("IS NULL: %d rows", n)
+	}
+	if n := count(mt.IsNotNull(notefd)); n != 3 {
+		t.Errorf("IS NOT NULL: %d rows", n)
+	}
+	if n := count(mt.Where(notefd, OpEq, String("b"))); n != 2 {
+		t.Errorf("= 'b': %d rows", n)
+	}
+	if n := count(mt.Where(notefd, OpNe, String("b"))); n != 1 {
+		t.Errorf("<> 'b': %d rows", n)
+	}
+	if n := count(mt.Where(notefd, OpEq, Null)); n != 0 {
+		t.Errorf("= NULL: %d rows", n)
+	}
+
+	mt.DeleteIndex(notefd)
+	if n := count(mt.IsNull(notefd)); n != 2 {
+		t.Errorf("IS NULL without index: %d rows", n)
+	}
+}
+
+func stringList(ss ...string) SortableList {
+	res := make(SortableList, len(ss))
+	for i, s := range ss {
+		res[i] = String(s)
+	}
+	return res
+}
+
+func checkIDs(t *testing.T, name string, it IDIterator, want ...string) {
+	t.Helper()
+	got := collectIDs(it)
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", name, got, want)
+	}
+	for i := range got {
+		if !got[i].ModelEqual(String(want[i])) {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReverseIterators(t *testing.T) {
+	a := stringList("a", "c", "d", "f", "g")
+	b := stringList("b", "c", "f", "h")
+	skipD := func(id ModelSortable) bool { return id.ModelEqual(String("d")) }
+
+	checkIDs(t, "column", NewReverseColumnIterator(a, skipD), "g", "f", "c", "a")
+	it := NewReverseColumnIterator(a, nil)
+	if !it.JumpTo(String("e")) || !it.NextID().ModelEqual(String("d")) {
+		t.Fatal("descending JumpTo must move to the first id not greater than argument")
+	}
+	checkIDs(t, "column after jump", it, "c", "a")
+	it = NewReverseColumnIterator(a, nil)
+	if it.JumpTo(String("0")) {
+		t.Error("JumpTo before the first id must fail")
+	}
+	if !it.JumpTo(String("z")) || !it.NextID().ModelEqual(String("g")) {
+		t.Error("JumpTo after the last id must move to the last id")
+	}
+	checkIDs(t, "column reverse", NewReverseColumnIterator(a, skipD).Reverse(), "a", "c", "f", "g")
+
+	merge := NewReverseMergeIterator(NewReverseColumnIterator(a, nil), NewReverseColumnIterator(b, nil))
+	checkIDs(t, "merge", merge.Clone(), "h", "g", "f", "d", "c", "b", "a")
+	if !merge.JumpTo(String("e")) || !merge.NextID().ModelEqual(String("d")) {
+		t.Fatal("wrong merge JumpTo")
+	}
+	checkIDs(t, "merge reverse", merge.Reverse(), "a", "b", "c", "d", "f", "g", "h")
+
+	inter := NewReverseIteratorIntersect()
+	inter.Append(NewReverseColumnIterator(a, nil))
+	inter.Append(NewReverseColumnIterator(b, nil))
+	checkIDs(t, "intersect", inter.Clone(), "f", "c")
+	checkIDs(t, "intersect reverse", inter.Reverse(), "c", "f")
+
+	diff := NewReverseIteratorIntersect()
+	diff.Append(NewReverseMergeIterator(NewReverseColumnIterator(a, nil), NewReverseColumnIterator(b, nil)))
+	diff.AppendDiff(NewReverseColumnIterator(stringList("c", "g"), nil))
+	checkIDs(t, "difference", diff, "h", "f", "d", "b", "a")
+
+	checkIDs(t, "reverse of ascending", Reverse(NewColumnIterator(b, nil)), "h", "f", "c", "b")
+
+	// children with other direction are reversed
+	checkIDs(t, "mixed merge", NewMergeIterator(NewColumnIterator(a, nil), NewReverseColumnIterator(b, nil)),
+		"a", "b", "c", "d", "f", "g", "h")
+	mixed := NewReverseIteratorIntersect()
+	mixed.Append(NewColumnIterator(a, nil))
+	mixed.Append(NewReverseColumnIterator(b, nil))
+	checkIDs(t, "mixed intersect", mixed, "f", "c")
+
+	// empty children have no range
+	empty := stringList()
+	checkIDs(t, "merge with empty first", NewMergeIterator(NewColumnIterator(empty, nil), NewColumnIterator(b, nil)),
+		"b", "c", "f", "h")
+	checkIDs(t, "merge with empty last", NewMergeIterator(NewColumnIterator(b, nil), NewColumnIterator(empty, nil)),
+		"b", "c", "f", "h")
+	checkIDs(t, "merge of empty", NewReverseMergeIterator(NewReverseColumnIterator(empty, nil)))
+	if l, r := NewMergeIterator(NewColumnIterator(empty, nil), NewColumnIterator(b, nil)).Range(); l != String("b") || r != String("h") {
+		t.Errorf("wrong merge range %v %v", l, r)
+	}
+}
@@ -1,6 +1,7 @@
 package inmemdb
 
 type MergeIterator struct {
+	desc        bool
 	iterators   []IDIterator
 	currid      ModelSortable
 	minheap     *IDAccHeap
@@ -11,11 +12,23 @@ type MergeIterator struct {
 }
 
 func NewMergeIterator(iterators ...IDIterator) *MergeIterator {
+	return newMergeIterator(false, iterators)
+}
+
+// NewReverseMergeIterator returns union of iterators in descending order
+func NewReverseMergeIterator(iterators ...IDIterator) *MergeIterator {
+	return newMergeIterator(true, iterators)
+}
+
+func newMergeIterator(desc bool, iterators []IDIterator) *MergeIterator {
 	if len(iterators) == 0 {
 		panic("iterators not defined")
 	}
 
+	// children with other direction are reversed
+	iterators = append([]IDIterator(nil), iterators...)
 	h := NewIDAccHeap(len(iterators))
+	h.Desc = desc
 	InitIDAccHeap(h)
 	maxSz := 0
 	var l, r ModelSortable
@@ -24,17 +37,25 @@ func NewMergeIterator(iterators ...IDIterator) *MergeIterator {
 		if it == nil {
 			continue
 		}
-		il, ir := it.Range()
-		if i == 0 || il.ModelLess(l) {
-			l = il
-		}
-		if i == 0 || r.ModelLess(ir) {
-			r = ir
+		if isDescending(it) != desc {
+			it = Reverse(it)
+			iterators[i] = it
 		}
 		lenList := it.Cardinality()
+		if lenList == 0 {
+			// empty iterators have no range
+			continue
+		}
 		if lenList > maxSz {
 			maxSz = lenList
 		}
+		il, ir := it.Range()
+		if l == nil || il.ModelLess(l) {
+			l = il
+		}
+		if r == nil || r.ModelLess(ir) {
+			r = ir
+		}
 		if it.HasNext() {
 			PushIDAccHeap(h, ElemHeapIDAcc{
 				ID:       it.NextID(),
@@ -44,6 +65,7 @@ func NewMergeIterator(iterators ...IDIterator) *MergeIterator {
 	}
 
 	return &MergeIterator{
+		desc:        desc,
 		iterators:   iterators,
 		minheap:     h,
 		min:         l,
@@ -63,6 +85,22 @@ func (iter *MergeIterator) Clone() IDIterator {
 	return rv
 }
 
+// Descending reports whether iterator walks in descending order
+func (iter *MergeIterator) Descending() bool {
+	return iter.desc
+}
+
+// Reverse returns union of reversed iterators
+func (iter *MergeIterator) Reverse() IDIterator {
+	its := make([]IDIterator, len(iter.iterators))
+	for i, it := range iter.iterators {
+		if it != nil {
+			its[i] = Reverse(it)
+		}
+	}
+	return newMergeIterator(!iter.desc, its)
+}
+
 func (iter *MergeIterator) JumpTo(id ModelSortable) bool {
 	if iter.lastJumpTo == id {
 		return iter.lastJumpOk
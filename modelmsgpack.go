@@ -0,0 +1,296 @@
+package inmemdb
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// MsgpackKey defines keys of encoded model object map
+type MsgpackKey int
+
+const (
+	// MsgpackKeyIndex uses FieldDescription.Idx as keys, it is compact,
+	// but encoder and decoder must have the same field order of model
+	MsgpackKeyIndex MsgpackKey = iota
+	// MsgpackKeyName uses store names as keys
+	MsgpackKeyName
+)
+
+var binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+// MarshalMsgpack encodes stored fields as MessagePack map with field indexes as keys.
+// Absent (nil) fields are not encoded, Null values are encoded as nil.
+func (mo ModelObject) MarshalMsgpack() ([]byte, error) {
+	return mo.AppendMsgpack(nil, MsgpackKeyIndex)
+}
+
+// AppendMsgpack appends MessagePack map of stored fields to b
+func (mo ModelObject) AppendMsgpack(b []byte, key MsgpackKey) ([]byte, error) {
+	mo.Load()
+	n := 0
+	for fdi, v := range mo.v {
+		if v != nil && mo.md.ColumnPtrs[fdi].IsStored() {
+			n++
+		}
+	}
+	b = mpAppendMapHeader(b, n)
+	for fdi, v := range mo.v {
+		fd := mo.md.ColumnPtrs[fdi]
+		if v == nil || !fd.IsStored() {
+			continue
+		}
+		if key == MsgpackKeyName {
+			b = mpAppendString(b, fd.Name)
+		} else {
+			b = mpAppendUint(b, uint64(fdi))
+		}
+		var err error
+		if b, err = appendMsgpackValue(b, v); err != nil {
+			return nil, fmt.Errorf("can't encode field %s: %w", fd.Name, err)
+		}
+	}
+	return b, nil
+}
+
+func appendMsgpackValue(b []byte, v interface{}) ([]byte, error) {
+	if IsNull(v) {
+		return mpAppendNil(b), nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		v = rv.Elem().Interface()
+	}
+	switch vv := v.(type) {
+	case UUIDv4:
+		return mpAppendBytes(b, vv.UUID[:]), nil
+	case time.Time:
+		return mpAppendTime(b, vv), nil
+	case []byte:
+		return mpAppendBytes(b, vv), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return mpAppendBool(b, rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mpAppendInt(b, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return mpAppendUint(b, rv.Uint()), nil
+	case reflect.Float32:
+		return mpAppendFloat32(b, float32(rv.Float())), nil
+	case reflect.Float64:
+		return mpAppendFloat64(b, rv.Float()), nil
+	case reflect.String:
+		return mpAppendString(b, rv.String()), nil
+	}
+
+	switch vv := v.(type) {
+	case encoding.BinaryMarshaler:
+		data, err := vv.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return mpAppendBytes(b, data), nil
+	case driver.Valuer:
+		dv, err := vv.Value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := dv.(driver.Valuer); !ok {
+			return appendMsgpackValue(b, dv)
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return mpAppendExt(b, mpExtJSON, data), nil
+}
+
+// UnmarshalMsgpack decodes MessagePack map with field indexes or store names as keys,
+// unknown keys are skipped
+func (mo ModelObject) UnmarshalMsgpack(b []byte) error {
+	r := &msgpackReader{b: b}
+	if err := mo.readMsgpack(r); err != nil {
+		return err
+	}
+	if !r.done() {
+		return fmt.Errorf("msgpack: %d bytes after model object", len(r.b)-r.pos)
+	}
+	return nil
+}
+
+func (mo ModelObject) readMsgpack(r *msgpackReader) error {
+	convErr, err := mo.readMsgpackFields(r)
+	if err != nil {
+		return err
+	}
+	return convErr
+}
+
+// readMsgpackFields reads whole map even if some field can't be converted,
+// err is a format error, convErr is the first conversion error
+func (mo ModelObject) readMsgpackFields(r *msgpackReader) (convErr error, err error) {
+	mo.Load()
+	n, err := r.readHeader(true)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		k, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+
+		var fd *FieldDescription
+		switch kk := k.(type) {
+		case int64:
+			if kk >= 0 && kk < int64(len(mo.md.ColumnPtrs)) {
+				fd = mo.md.ColumnPtrs[kk]
+			}
+		case uint64:
+			if kk < uint64(len(mo.md.ColumnPtrs)) {
+				fd = mo.md.ColumnPtrs[kk]
+			}
+		case string:
+			fd = mo.md.ColumnByName[kk]
+		}
+		if fd == nil || !fd.IsStored() {
+			continue
+		}
+
+		cv, err := convertMsgpackValue(v, fd.StructField.Type)
+		if err != nil {
+			if convErr == nil {
+				convErr = fmt.Errorf("can't convert field %s to %s: %w", fd.Name, fd.StructField.Type, err)
+			}
+			continue
+		}
+		mo.v[fd.Idx] = cv
+	}
+	return convErr, nil
+}
+
+func convertMsgpackValue(v interface{}, t reflect.Type) (interface{}, error) {
+	et := t
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	switch vv := v.(type) {
+	case nil:
+		return Null, nil
+	case mpJSON:
+		p := reflect.New(t)
+		if err := json.Unmarshal(vv, p.Interface()); err != nil {
+			return nil, err
+		}
+		return p.Elem().Interface(), nil
+	case mpExt:
+		return nil, fmt.Errorf("unknown msgpack extension type %d", vv.Type)
+	case []byte:
+		if et != reflect.TypeOf(vv) && reflect.PtrTo(et).Implements(binaryUnmarshalerType) && !reflect.PtrTo(et).Implements(converterType) {
+			p := reflect.New(et)
+			if err := p.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(vv); err != nil {
+				return nil, err
+			}
+			v = p.Elem().Interface()
+		}
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(vv)
+		if err != nil {
+			return nil, err
+		}
+		p := reflect.New(t)
+		if err := json.Unmarshal(data, p.Interface()); err != nil {
+			return nil, err
+		}
+		return p.Elem().Interface(), nil
+	}
+	return ConvertToType(v, t)
+}
+
+// MarshalMsgpack encodes all rows as MessagePack array of model objects with field indexes as keys
+func (mt *ModelTable) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 0, mt.rows.len()*64)
+	b = mpAppendArrayHeader(b, mt.rows.len())
+	for i := 0; i < mt.rows.len(); i++ {
+		var err error
+		if b, err = mt.rows.row(i).AppendMsgpack(b, MsgpackKeyIndex); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// EncodeMsgpack writes rows with ids from iter as a stream of MessagePack maps (without array header),
+// all rows are written if iter is nil
+func (mt *ModelTable) EncodeMsgpack(w io.Writer, iter IDIterator, key MsgpackKey) error {
+	if iter == nil {
+		iter = NewColumnIterator(mt, nil)
+	}
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+	var b []byte
+	for iter.HasNext() {
+		mo, ok := mt.Get(iter.NextID())
+		if !ok {
+			continue
+		}
+		var err error
+		if b, err = mo.AppendMsgpack(b[:0], key); err != nil {
+			return err
+		}
+		if _, err := bw.Write(b); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// UnmarshalMsgpack upserts rows from MessagePack array or stream of maps,
+// invalid rows are skipped and returned as ErrorRows
+func (mt *ModelTable) UnmarshalMsgpack(b []byte) error {
+	r := &msgpackReader{b: b}
+	n := -1
+	if len(b) > 0 && (b[0]&0xf0 == 0x90 || b[0] == mpArray16 || b[0] == mpArray32) {
+		var err error
+		if n, err = r.readHeader(false); err != nil {
+			return err
+		}
+	}
+
+	var rowErrs ErrorRows
+	for row := 1; (n < 0 && !r.done()) || row <= n; row++ {
+		mo := NewModelObject(mt.md)
+		err, ferr := mo.readMsgpackFields(r)
+		if ferr != nil {
+			// stream position is unknown after format errors
+			return fmt.Errorf("row %d: %w", row, ferr)
+		}
+		if err == nil {
+			err = mo.Validate()
+		}
+		if err == nil {
+			err = mt.Upsert(mo)
+		}
+		if err != nil {
+			mo.Close()
+			rowErrs = append(rowErrs, ErrorRow{Row: row, Err: err})
+		}
+	}
+
+	if len(rowErrs) > 0 {
+		return rowErrs
+	}
+	return nil
+}
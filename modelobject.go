@@ -14,8 +14,9 @@ import (
 )
 
 type ModelObject struct {
-	md *ModelDescription
-	v  []interface{}
+	md   *ModelDescription
+	v    []interface{}
+	orig []interface{} // snapshot of v for change tracking
 
 	aliasbuf [4]byte
 
@@ -23,6 +24,8 @@ type ModelObject struct {
 	cols           []string
 	fds            []*FieldDescription
 	lastAlias      string
+
+	view *rowView // source of not loaded fields, see Load
 }
 
 var valPool = sync.Pool{}
@@ -94,15 +97,36 @@ func (mo ModelObject) MD() *ModelDescription {
 
 func (mo *ModelObject) Close() {
 	putValSlice(mo.v)
+	putValSlice(mo.orig)
 	mo.v = nil
+	mo.orig = nil
 	mo.cols = nil
 	mo.fds = nil
 	mo.lastColScanner = nil
 	mo.md = nil
 	mo.lastAlias = ""
+	mo.view = nil
+}
+
+// Load reads all fields of row view, that is returned by table with columnar storage, and detaches it from the table.
+// Fields of row view are read on first access, view of changed or deleted row returns values it was created with.
+// Load does nothing for other objects.
+func (mo ModelObject) Load() {
+	if mo.view != nil {
+		mo.view.loadAll(mo.v)
+	}
+}
+
+// val returns raw value by FieldDescription.Idx, field of row view is read on first access
+func (mo ModelObject) val(idx int) interface{} {
+	if mo.view != nil {
+		mo.view.load(mo.v, idx)
+	}
+	return mo.v[idx]
 }
 
 func (mo ModelObject) Clear() {
+	mo.Load()
 	for i := range mo.v {
 		mo.v[i] = nil
 	}
@@ -121,8 +145,26 @@ func (fnil *NullType) ConvertFrom(v interface{}) error {
 var Null NullType
 
 func (mo ModelObject) MarshalJSON() ([]byte, error) {
+	return mo.MarshalJSONProjection(nil)
+}
+
+// MarshalJSONProjection encodes only fields of projection p, all fields are encoded if p is nil
+func (mo ModelObject) MarshalJSONProjection(p *Projection) ([]byte, error) {
 	b := GetBuffer()
+	defer PutBuffer(b)
 
+	if err := mo.writeJSON(b, p); err != nil {
+		return nil, err
+	}
+	// buffer returns to pool, so result must be copied
+	res := make([]byte, b.Len())
+	copy(res, b.Bytes())
+
+	return res, nil
+}
+
+func (mo ModelObject) writeJSON(b *bytes.Buffer, p *Projection) error {
+	mo.Load()
 	b.Grow(len(mo.v) * 32)
 	enc := json.NewEncoder(b)
 	b.WriteByte('{')
@@ -133,6 +175,10 @@ func (mo ModelObject) MarshalJSON() ([]byte, error) {
 		if v == nil || fd.JsonName == "" {
 			continue
 		}
+		sub, ok := p.field(fd)
+		if !ok {
+			continue
+		}
 
 		if fd.JsonOmitEmpty && (isnull || reflect.ValueOf(v).IsZero()) {
 			continue
@@ -147,20 +193,25 @@ func (mo ModelObject) MarshalJSON() ([]byte, error) {
 		b.WriteByte(':')
 		if isnull {
 			b.WriteString("null")
+		} else if sub != nil {
+			if err := writeProjectedJSON(b, enc, v, sub); err != nil {
+				return err
+			}
 		} else {
-			enc.Encode(v)
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+			b.Truncate(b.Len() - 1) // Encode appends newline
 		}
 		comma = true
 	}
 	b.WriteByte('}')
-	res := b.Bytes()
-	PutBuffer(b)
-
-	return res, nil
+	return nil
 }
 
 // keys = json names
 func (mo *ModelObject) FromMap(data map[string]interface{}) error {
+	mo.Load()
 	for k, v := range data {
 		fd, ok := mo.md.ColumnByJsonName[k]
 		if !ok {
@@ -210,6 +261,7 @@ func (mo *ModelObject) UnmarshalJSON(b []byte) error {
 }
 
 func (mo ModelObject) Validate() error {
+	mo.Load()
 	var validationErrors ErrorValidations
 
 	for fdi, v := range mo.v {
@@ -279,6 +331,7 @@ func ByteSlice2String(bs []byte) string {
 // each other!
 // Only one table alias (or none) is supported
 func (mo *ModelObject) RowScan(r sqlx.ColScanner, aliases ...string) error {
+	mo.Load()
 	if mo.lastColScanner != r {
 		columns, err := r.Columns()
 		if err != nil {
@@ -365,10 +418,11 @@ func (mo *ModelObject) RowScan(r sqlx.ColScanner, aliases ...string) error {
 }
 
 func (mo ModelObject) Field(fd *FieldDescription) interface{} {
-	return mo.v[fd.Idx]
+	return mo.val(fd.Idx)
 }
 
 func (mo ModelObject) Delete(fd *FieldDescription) {
+	mo.Load()
 	mo.v[fd.Idx] = nil
 }
 
@@ -377,6 +431,7 @@ func (mo ModelObject) IDField() interface{} {
 }
 
 func (mo ModelObject) SetIDField(id interface{}) error {
+	mo.Load()
 	if mo.md.IdField.StructField.Type == reflect.TypeOf(id) {
 		mo.v[mo.md.IdField.Idx] = id
 		return nil
@@ -390,6 +445,7 @@ func (mo ModelObject) SetIDField(id interface{}) error {
 }
 
 func (mo ModelObject) FieldCount() int {
+	mo.Load()
 	cnt := 0
 	for _, v := range mo.v {
 		if v != nil {
@@ -400,9 +456,11 @@ func (mo ModelObject) FieldCount() int {
 }
 
 func (mo ModelObject) SetField(fd *FieldDescription, val interface{}) error {
+	mo.Load()
 	_, ok1 := val.(ModelObject)
 	_, ok2 := val.(*ModelObject)
-	if fd.StructField.Type == reflect.TypeOf(val) || ok1 || ok2 || !fd.IsStored() {
+	_, isnull := val.(NullType)
+	if fd.StructField.Type == reflect.TypeOf(val) || ok1 || ok2 || isnull || !fd.IsStored() {
 		mo.v[fd.Idx] = val
 		return nil
 	}
@@ -415,6 +473,7 @@ func (mo ModelObject) SetField(fd *FieldDescription, val interface{}) error {
 }
 
 func (mo ModelObject) DBData() (cols []string, vals []interface{}) {
+	mo.Load()
 	ln := mo.FieldCount()
 	cols = make([]string, 0, ln)
 	vals = make([]interface{}, 0, ln)
@@ -430,12 +489,15 @@ func (mo ModelObject) DBData() (cols []string, vals []interface{}) {
 }
 
 func (mo ModelObject) CopyTo(dest *ModelObject) {
+	mo.Load()
+	dest.Load()
 	for fdi, v := range mo.v {
 		dest.v[fdi] = v
 	}
 }
 
 func (mo ModelObject) Walk(f func(fd *FieldDescription, value interface{})) {
+	mo.Load()
 	for fdi, v := range mo.v {
 		fd := mo.md.ColumnPtrs[fdi]
 		if v != nil {
@@ -444,7 +506,37 @@ func (mo ModelObject) Walk(f func(fd *FieldDescription, value interface{})) {
 	}
 }
 
+// StructToModel is implemented by generated accessors (see cmd/inmemdbgen), FromStruct uses it instead of reflection
+type StructToModel interface {
+	ToModelObject(mo ModelObject) error
+}
+
+// StructFromModel is implemented by generated accessors (see cmd/inmemdbgen), ToStruct uses it instead of reflection
+type StructFromModel interface {
+	FromModelObject(mo ModelObject) error
+}
+
+// FieldAt returns raw value by FieldDescription.Idx, it is used by generated accessors
+func (mo ModelObject) FieldAt(idx int) interface{} {
+	return mo.val(idx)
+}
+
+// SetFieldAt sets raw value by FieldDescription.Idx without conversion, it is used by generated accessors
+func (mo ModelObject) SetFieldAt(idx int, val interface{}) {
+	mo.Load()
+	mo.v[idx] = val
+}
+
 func (mo *ModelObject) FromStruct(src interface{}) error {
+	if a, ok := src.(StructToModel); ok {
+		return a.ToModelObject(*mo)
+	}
+	return mo.FromStructReflect(src)
+}
+
+// FromStructReflect is FromStruct without generated accessors
+func (mo *ModelObject) FromStructReflect(src interface{}) error {
+	mo.Load()
 	ret := reflect.Indirect(reflect.ValueOf(src))
 	if ret.Kind() != reflect.Struct {
 		return fmt.Errorf("source must be a struct")
@@ -466,6 +558,15 @@ func (mo *ModelObject) FromStruct(src interface{}) error {
 
 // target is pointer to model struct
 func (mo ModelObject) ToStruct(target interface{}) error {
+	if a, ok := target.(StructFromModel); ok {
+		return a.FromModelObject(mo)
+	}
+	return mo.ToStructReflect(target)
+}
+
+// ToStructReflect is ToStruct without generated accessors
+func (mo ModelObject) ToStructReflect(target interface{}) error {
+	mo.Load()
 	ret := reflect.ValueOf(target)
 	if ret.Kind() != reflect.Ptr || ret.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("target must be a pointer to struct")
@@ -524,6 +625,10 @@ func (mo ModelObject) ToStruct(target interface{}) error {
 	return nil
 }
 
-func (mo ModelObject) GetColumnsByFieldNames(fieldNames ...string) (res []*FieldDescription) {
+func (mo ModelObject) GetColumnsByFieldNames(fieldNames ...string) ([]*FieldDescription, error) {
 	return mo.md.GetColumnsByFieldNames(fieldNames...)
 }
+
+func (mo ModelObject) MustGetColumnsByFieldNames(fieldNames ...string) []*FieldDescription {
+	return mo.md.MustGetColumnsByFieldNames(fieldNames...)
+}
@@ -0,0 +1,301 @@
+package inmemdb
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestObjMO struct {
+	ID    UUIDv4  `json:"id"`
+	Name  String  `json:"name"`
+	Note  *String `json:"note,omitempty"`
+	Count int     `json:"count"`
+}
+
+func (t TestObjMO) StoreName() string { return "testobjmo" }
+
+func newTestObj(t *testing.T) (*ModelDescription, ModelObject) {
+	tt := TestObjMO{}
+	md, err := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mo := NewModelObject(md)
+	note := String("note")
+	if err := mo.FromStruct(TestObjMO{ID: NewV4(), Name: "name", Note: &note, Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	return md, mo
+}
+
+func TestModelObjectChanges(t *testing.T) {
+	md, mo := newTestObj(t)
+	fds := md.MustGetColumnsByFieldNames("Name", "Note", "Count")
+
+	if len(mo.Dirty()) != 4 {
+		t.Errorf("all fields must be dirty without snapshot: %v", mo.Dirty())
+	}
+	mo.Snapshot()
+	if mo.IsDirty() {
+		t.Error("must be clean after snapshot")
+	}
+
+	mo.SetField(fds[0], "name")
+	note := String("note")
+	mo.SetField(fds[1], &note)
+	if mo.IsDirty() {
+		t.Errorf("equal values must not be dirty: %v", mo.Changes())
+	}
+
+	mo.SetField(fds[0], "new")
+	mo.Delete(fds[2])
+	changes := mo.Changes()
+	if len(changes) != 2 || changes[0].FD != fds[0] || changes[0].Old != String("name") || changes[0].New != String("new") {
+		t.Fatalf("wrong changes: %v", changes)
+	}
+	cols, vals := mo.DirtyDBData()
+	if !reflect.DeepEqual(cols, []string{"name", "count"}) || vals[1] != Null {
+		t.Errorf("wrong update data: %v %v", cols, vals)
+	}
+
+	mo.Rollback()
+	if mo.IsDirty() {
+		t.Errorf("must be clean after rollback: %v", mo.Changes())
+	}
+}
+
+func TestModelObjectPatch(t *testing.T) {
+	md, mo := newTestObj(t)
+	fds := md.MustGetColumnsByFieldNames("Name", "Note", "Count")
+
+	if err := mo.ApplyMergePatch([]byte(`{"name":"merged","note":null,"unknown":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if mo.Field(fds[0]) != String("merged") || mo.Field(fds[1]) != Null || mo.Field(fds[2]) != 1 {
+		t.Errorf("wrong merge result: %v", mo)
+	}
+	if err := mo.ApplyMergePatch([]byte(`{"count":null}`)); err == nil {
+		t.Error("not nullable field can't be removed")
+	}
+
+	orig := NewModelObject(md)
+	mo.CopyTo(&orig)
+
+	err := mo.ApplyJSONPatch([]byte(`[
+		{"op":"test","path":"/name","value":"merged"},
+		{"op":"replace","path":"/count","value":5},
+		{"op":"add","path":"/note","value":"added"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mo.Field(fds[2]) != 5 || *(mo.Field(fds[1]).(*String)) != "added" {
+		t.Errorf("wrong patch result: %v", mo)
+	}
+
+	err = mo.ApplyJSONPatch([]byte(`[
+		{"op":"replace","path":"/count","value":6},
+		{"op":"test","path":"/name","value":"other"}
+	]`))
+	if err == nil || mo.Field(fds[2]) != 5 {
+		t.Errorf("failed patch must not change object: %v", err)
+	}
+
+	patch, err := DiffAsPatch(orig, mo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 2 || patch[0].Path != "/note" || patch[1].Path != "/count" {
+		t.Fatalf("wrong diff: %v", patch)
+	}
+	b, _ := json.Marshal(patch)
+	if err := orig.ApplyJSONPatch(b); err != nil {
+		t.Fatal(err)
+	}
+	if diff, _ := DiffAsPatch(orig, mo); len(diff) != 0 {
+		t.Errorf("objects must be equal after diff patch: %v", diff)
+	}
+}
+
+type TestOwnerMO struct {
+	ID    UUIDv4 `json:"id"`
+	Name  String `json:"name"`
+	Email String `json:"email"`
+}
+
+func (t TestOwnerMO) StoreName() string { return "testownermo" }
+
+type TestItemMO struct {
+	ID        UUIDv4       `json:"id"`
+	Name      String       `json:"name"`
+	Note      *String      `json:"note"`
+	OwnerID   UUIDv4       `json:"ownerId"`
+	Owner     *TestOwnerMO `json:"owner"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+func (t TestItemMO) StoreName() string { return "testitemmo" }
+
+func TestModelObjectProjection(t *testing.T) {
+	tt := TestItemMO{}
+	md, err := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	omd, err := relatedModelDescription(md.ColumnByJsonName["owner"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := NewModelObject(omd)
+	owner.FromStruct(TestOwnerMO{ID: NewV4(), Name: "owner", Email: "owner@example.com"})
+	mo := NewModelObject(md)
+	mo.FromStruct(TestItemMO{ID: NewV4(), Name: "item", OwnerID: owner.IDField().(UUIDv4)})
+	mo.SetField(md.ColumnByJsonName["owner"], owner)
+
+	p, err := ParseFieldMask(md, "name, owner.email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "name,owner.email" {
+		t.Errorf("wrong projection: %s", p)
+	}
+	b, err := json.Marshal(mo.Project(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"name":"item","owner":{"email":"owner@example.com"}}` {
+		t.Errorf("wrong projected json: %s", b)
+	}
+
+	// struct values of relations are projected by json names
+	mo.SetField(md.ColumnByJsonName["owner"], &TestOwnerMO{Name: "owner", Email: "owner@example.com"})
+	if b, _ = mo.MarshalJSONProjection(p); string(b) != `{"name":"item","owner":{"email":"owner@example.com"}}` {
+		t.Errorf("wrong projected json: %s", b)
+	}
+
+	if _, err := ParseFieldMask(md, "name.email"); err == nil {
+		t.Error("nested fields of not related field must fail")
+	}
+	if _, err := ParseFieldMask(md, "owner.unknown"); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("expected ErrUnknownField, got %v", err)
+	}
+}
+
+func TestDiffAsPatchRoundtrip(t *testing.T) {
+	tt := TestItemMO{}
+	md, err := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	note := String("note")
+	ownerID := NewV4()
+	a, b := NewModelObject(md), NewModelObject(md)
+	a.FromStruct(TestItemMO{ID: NewV4(), Name: "a", Note: &note, OwnerID: ownerID, CreatedAt: now, UpdatedAt: now})
+	b.FromStruct(TestItemMO{ID: a.IDField().(UUIDv4), OwnerID: ownerID, CreatedAt: now, UpdatedAt: now.Add(time.Hour)})
+	b.SetField(md.ColumnByJsonName["owner"], &TestOwnerMO{ID: ownerID, Name: "owner"})
+	b.SetField(md.ColumnByJsonName["name"], Null)
+
+	patch, err := DiffAsPatch(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(patch)
+	if err := a.ApplyJSONPatch(raw); err != nil {
+		t.Fatalf("%s: %v", raw, err)
+	}
+	var got, want TestItemMO
+	if err := a.ToStruct(&got); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ToStruct(&want); err != nil {
+		t.Fatal(err)
+	}
+	// timestamps and relations are not patchable
+	want.UpdatedAt, want.Owner = got.UpdatedAt, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong roundtrip: %+v != %+v", got, want)
+	}
+}
+
+func TestModelObjectMsgpack(t *testing.T) {
+	md, mo := newTestObj(t)
+	fds := md.MustGetColumnsByFieldNames("ID", "Name", "Note", "Count")
+
+	for _, key := range []MsgpackKey{MsgpackKeyIndex, MsgpackKeyName} {
+		b, err := mo.AppendMsgpack(nil, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mo2 := NewModelObject(md)
+		if err := mo2.UnmarshalMsgpack(b); err != nil {
+			t.Fatal(err)
+		}
+		for _, fd := range fds {
+			if !valuesEqual(mo.Field(fd), mo2.Field(fd)) {
+				t.Errorf("key %d: field %s: %v != %v", key, fd.Name, mo.Field(fd), mo2.Field(fd))
+			}
+		}
+	}
+
+	mo.SetField(fds[2], Null)
+	mo.Delete(fds[3])
+	b, err := mo.MarshalMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mo2 := NewModelObject(md)
+	if err := mo2.UnmarshalMsgpack(b); err != nil {
+		t.Fatal(err)
+	}
+	if mo2.Field(fds[2]) != Null || mo2.Field(fds[3]) != nil {
+		t.Errorf("null and absent fields must be preserved: %v %v", mo2.Field(fds[2]), mo2.Field(fds[3]))
+	}
+
+	mt := NewModelTable(md, 2)
+	if err := mt.Upsert(mo); err != nil {
+		t.Fatal(err)
+	}
+	b, err = mt.MarshalMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt2 := NewModelTable(md, 2)
+	if err := mt2.UnmarshalMsgpack(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := mt2.Get(mo.IDField().(UUIDv4)); !ok || got.Field(fds[1]) != String("name") {
+		t.Errorf("wrong table roundtrip: %v", got)
+	}
+
+	// huge header is rejected before allocation
+	if _, err := (&msgpackReader{b: []byte{mpArray32, 0xff, 0xff, 0xff, 0xff}}).readValue(); !errors.Is(err, errMsgpackShort) {
+		t.Errorf("expected short data error, got %v", err)
+	}
+
+	// row with not convertible field is skipped, other rows are loaded
+	badID := NewV4()
+	bad := mpAppendMapHeader(nil, 2)
+	bad = mpAppendInt(bad, int64(fds[0].Idx))
+	if bad, err = appendMsgpackValue(bad, badID); err != nil {
+		t.Fatal(err)
+	}
+	bad = mpAppendString(mpAppendInt(bad, int64(fds[3].Idx)), "x")
+	good, err := mo.MarshalMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt3 := NewModelTable(md, 2)
+	var rowErrs ErrorRows
+	if err := mt3.UnmarshalMsgpack(append(bad, good...)); !errors.As(err, &rowErrs) || len(rowErrs) != 1 || rowErrs[0].Row != 1 {
+		t.Fatalf("expected error of row 1, got %v", err)
+	}
+	if _, ok := mt3.Get(badID); ok || mt3.Len() != 1 {
+		t.Errorf("wrong rows after partial load: %d", mt3.Len())
+	}
+}
@@ -0,0 +1,460 @@
+package inmemdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONPatchOp is an operation of RFC 6902 JSON Patch
+type JSONPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type JSONPatch []JSONPatchOp
+
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+	PatchOpMove    = "move"
+	PatchOpCopy    = "copy"
+	PatchOpTest    = "test"
+)
+
+// patchable fields are the same as for FromMap: not relations and not internal fields
+func (mo ModelObject) patchable(fd *FieldDescription) bool {
+	return fd.JsonName != "" && fd.Relation.Type == RelationTypeNotRelation &&
+		fd != mo.md.CreatedAtField && fd != mo.md.UpdatedAtField && fd != mo.md.DeletedAtField
+}
+
+// nullValue is a value of removed field: NULL for nullable fields
+func (mo ModelObject) nullValue(fd *FieldDescription) (interface{}, error) {
+	if !fd.Nullable {
+		return nil, ErrorField{Type: mo.md.ModelType, Field: fd.JsonName, Value: "null", Err: ErrConstraint}
+	}
+	return Null, nil
+}
+
+func (mo ModelObject) decodeField(fd *FieldDescription, raw []byte) (interface{}, error) {
+	if isJSONNull(raw) {
+		return mo.nullValue(fd)
+	}
+	pv := reflect.New(fd.StructField.Type)
+	if err := json.Unmarshal(raw, pv.Interface()); err != nil {
+		return nil, fmt.Errorf("can't convert json field %s to %s: %w", fd.JsonName, fd.StructField.Type, err)
+	}
+	return pv.Elem().Interface(), nil
+}
+
+func isJSONNull(raw []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// jsonValue converts field value to generic json value (map, slice, string, float64, bool or nil)
+func jsonValue(v interface{}) (interface{}, error) {
+	if IsNull(v) {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var res interface{}
+	err = json.Unmarshal(b, &res)
+	return res, err
+}
+
+// ApplyMergePatch applies RFC 7386 JSON Merge Patch with json names of fields as keys.
+// Absent keys are not changed, null removes the field value (sets NULL), objects are merged
+// into the current values of struct and map fields. Unknown keys are skipped like in UnmarshalJSON.
+// The model object is not changed on error.
+func (mo ModelObject) ApplyMergePatch(patch []byte) error {
+	mo.Load()
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &doc); err != nil {
+		return fmt.Errorf("merge patch must be a json object: %w", err)
+	}
+
+	work := getValSlice(len(mo.v))
+	defer putValSlice(work)
+	copy(work, mo.v)
+
+	for k, raw := range doc {
+		fd, ok := mo.md.ColumnByJsonName[k]
+		if !ok || !mo.patchable(fd) {
+			continue
+		}
+
+		raw = bytes.TrimSpace(raw)
+		cur := work[fd.Idx]
+		if len(raw) > 0 && raw[0] == '{' && !IsNull(cur) {
+			switch fd.ElemType.Kind() {
+			case reflect.Struct, reflect.Map:
+				target, err := jsonValue(cur)
+				if err != nil {
+					return err
+				}
+				var p interface{}
+				if err := json.Unmarshal(raw, &p); err != nil {
+					return err
+				}
+				if raw, err = json.Marshal(mergePatch(target, p)); err != nil {
+					return err
+				}
+			}
+		}
+
+		nv, err := mo.decodeField(fd, raw)
+		if err != nil {
+			return err
+		}
+		work[fd.Idx] = nv
+	}
+
+	copy(mo.v, work)
+	return nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// ApplyJSONPatch applies RFC 6902 JSON Patch, first token of every path is a json name of field,
+// the rest of path addresses the json representation of field value.
+// Removed fields get NULL values. All operations are applied atomically.
+func (mo ModelObject) ApplyJSONPatch(patch []byte) error {
+	mo.Load()
+	var ops JSONPatch
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return fmt.Errorf("json patch must be an array of operations: %w", err)
+	}
+
+	work := getValSlice(len(mo.v))
+	defer putValSlice(work)
+	copy(work, mo.v)
+
+	for i, op := range ops {
+		if err := mo.applyPatchOp(work, op); err != nil {
+			return fmt.Errorf("json patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	copy(mo.v, work)
+	return nil
+}
+
+func (mo ModelObject) patchPath(path string) (*FieldDescription, []string, error) {
+	tokens, err := parseJSONPointer(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("whole object can't be patched")
+	}
+	fd, ok := mo.md.ColumnByJsonName[tokens[0]]
+	if !ok || fd.JsonName == "" {
+		return nil, nil, ErrorField{Type: mo.md.ModelType, Field: tokens[0], Err: ErrUnknownField}
+	}
+	return fd, tokens[1:], nil
+}
+
+func (mo ModelObject) patchGet(work []interface{}, path string) (interface{}, error) {
+	fd, tokens, err := mo.patchPath(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := work[fd.Idx]
+	if cur == nil {
+		return nil, fmt.Errorf("path %s not found", path)
+	}
+	doc, err := jsonValue(cur)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPointerGet(doc, tokens)
+}
+
+func (mo ModelObject) patchSet(work []interface{}, op, path string, val interface{}) error {
+	fd, tokens, err := mo.patchPath(path)
+	if err != nil {
+		return err
+	}
+	if !mo.patchable(fd) {
+		return ErrorField{Type: mo.md.ModelType, Field: fd.JsonName, Err: ErrConstraint}
+	}
+	cur := work[fd.Idx]
+
+	if len(tokens) == 0 {
+		switch op {
+		case PatchOpRemove:
+			if cur == nil {
+				return fmt.Errorf("path %s not found", path)
+			}
+			nv, err := mo.nullValue(fd)
+			if err != nil {
+				return err
+			}
+			work[fd.Idx] = nv
+			return nil
+		case PatchOpReplace:
+			if cur == nil {
+				return fmt.Errorf("path %s not found", path)
+			}
+		}
+	} else {
+		if cur == nil {
+			return fmt.Errorf("path %s not found", path)
+		}
+		doc, err := jsonValue(cur)
+		if err != nil {
+			return err
+		}
+		if val, err = jsonPointerSet(doc, tokens, op, val); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	nv, err := mo.decodeField(fd, b)
+	if err != nil {
+		return err
+	}
+	work[fd.Idx] = nv
+	return nil
+}
+
+func (mo ModelObject) applyPatchOp(work []interface{}, op JSONPatchOp) error {
+	var val interface{}
+	switch op.Op {
+	case PatchOpAdd, PatchOpReplace, PatchOpTest:
+		if len(op.Value) == 0 {
+			return fmt.Errorf("value is required")
+		}
+		if err := json.Unmarshal(op.Value, &val); err != nil {
+			return err
+		}
+	case PatchOpMove, PatchOpCopy:
+		v, err := mo.patchGet(work, op.From)
+		if err != nil {
+			return err
+		}
+		val = v
+	}
+
+	switch op.Op {
+	case PatchOpAdd, PatchOpReplace, PatchOpRemove:
+		return mo.patchSet(work, op.Op, op.Path, val)
+	case PatchOpCopy:
+		return mo.patchSet(work, PatchOpAdd, op.Path, val)
+	case PatchOpMove:
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("can't move %s into its child", op.From)
+		}
+		if err := mo.patchSet(work, PatchOpRemove, op.From, nil); err != nil {
+			return err
+		}
+		return mo.patchSet(work, PatchOpAdd, op.Path, val)
+	case PatchOpTest:
+		cur, err := mo.patchGet(work, op.Path)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(cur, val) {
+			return fmt.Errorf("test failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported operation %q", op.Op)
+}
+
+// DiffAsPatch returns JSON Patch that transforms patchable fields of a to b, fields are compared
+// by their json values. Absent and NULL values of not nullable fields are patched by zero values.
+func DiffAsPatch(a, b ModelObject) (JSONPatch, error) {
+	if a.md != b.md {
+		return nil, fmt.Errorf("%w: model descriptions of objects are not equal", ErrConstraint)
+	}
+	a.Load()
+	b.Load()
+	var res JSONPatch
+	for fdi, fd := range a.md.ColumnPtrs {
+		if !a.patchable(fd) {
+			continue
+		}
+		av, bv := a.v[fdi], b.v[fdi]
+		path := "/" + escapeJSONPointer(fd.JsonName)
+		switch {
+		case av == nil && bv == nil:
+			continue
+		case IsNull(bv) && !fd.Nullable:
+			bv = reflect.Zero(fd.StructField.Type).Interface()
+		case bv == nil:
+			if !IsNull(av) {
+				res = append(res, JSONPatchOp{Op: PatchOpRemove, Path: path})
+			}
+			continue
+		}
+
+		bj, err := jsonValue(bv)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(bj)
+		if err != nil {
+			return nil, err
+		}
+		if av == nil {
+			res = append(res, JSONPatchOp{Op: PatchOpAdd, Path: path, Value: raw})
+			continue
+		}
+		aj, err := jsonValue(av)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(aj, bj) {
+			res = append(res, JSONPatchOp{Op: PatchOpReplace, Path: path, Value: raw})
+		}
+	}
+	return res, nil
+}
+
+// RFC 6901 JSON Pointer
+
+func parseJSONPointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path[0] != '/' {
+		return nil, fmt.Errorf("json pointer %q must start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func jsonArrayIndex(arr []interface{}, token string, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return len(arr), nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (token != "0" && token[0] == '0') {
+		return 0, fmt.Errorf("wrong array index %q", token)
+	}
+	max := len(arr) - 1
+	if allowEnd {
+		max++
+	}
+	if idx > max {
+		return 0, fmt.Errorf("array index %d out of range", idx)
+	}
+	return idx, nil
+}
+
+func jsonPointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	for _, t := range tokens {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[t]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", t)
+			}
+			doc = v
+		case []interface{}:
+			idx, err := jsonArrayIndex(d, t, false)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[idx]
+		default:
+			return nil, fmt.Errorf("member %q not found", t)
+		}
+	}
+	return doc, nil
+}
+
+// jsonPointerSet applies add, replace or remove operation and returns changed doc
+func jsonPointerSet(doc interface{}, tokens []string, op string, val interface{}) (interface{}, error) {
+	t := tokens[0]
+	last := len(tokens) == 1
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		cur, ok := d[t]
+		if last {
+			if !ok && op != PatchOpAdd {
+				return nil, fmt.Errorf("member %q not found", t)
+			}
+			if op == PatchOpRemove {
+				delete(d, t)
+			} else {
+				d[t] = val
+			}
+			return d, nil
+		}
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", t)
+		}
+		nv, err := jsonPointerSet(cur, tokens[1:], op, val)
+		if err != nil {
+			return nil, err
+		}
+		d[t] = nv
+		return d, nil
+
+	case []interface{}:
+		idx, err := jsonArrayIndex(d, t, last && op == PatchOpAdd)
+		if err != nil {
+			return nil, err
+		}
+		if !last {
+			nv, err := jsonPointerSet(d[idx], tokens[1:], op, val)
+			if err != nil {
+				return nil, err
+			}
+			d[idx] = nv
+			return d, nil
+		}
+		switch op {
+		case PatchOpAdd:
+			d = append(d, nil)
+			copy(d[idx+1:], d[idx:])
+			d[idx] = val
+		case PatchOpReplace:
+			d[idx] = val
+		case PatchOpRemove:
+			d = append(d[:idx], d[idx+1:]...)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("member %q not found", t)
+}
@@ -0,0 +1,222 @@
+package inmemdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Projection is a set of fields for output, related models may have nested projections
+type Projection struct {
+	md     *ModelDescription
+	fields map[int]*Projection // key is FieldDescription.Idx, nil value means the whole field
+}
+
+func NewProjection(md *ModelDescription, fds ...*FieldDescription) *Projection {
+	p := &Projection{
+		md:     md,
+		fields: make(map[int]*Projection, len(fds)),
+	}
+	for _, fd := range fds {
+		p.fields[fd.Idx] = nil
+	}
+	return p
+}
+
+// ParseFieldMask parses comma separated json names of fields, nested fields of related models
+// are separated by dots, e.g. "id,name,owner.email"
+func ParseFieldMask(md *ModelDescription, mask string) (*Projection, error) {
+	p := NewProjection(md)
+	for _, path := range strings.Split(mask, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if err := p.addPath(strings.Split(path, ".")); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *Projection) addPath(path []string) error {
+	fd, ok := p.md.ColumnByJsonName[path[0]]
+	if !ok || fd.JsonName == "" {
+		return ErrorField{Type: p.md.ModelType, Field: path[0], Err: ErrUnknownField}
+	}
+	sub, ok := p.fields[fd.Idx]
+	if len(path) == 1 {
+		p.fields[fd.Idx] = nil
+		return nil
+	}
+	if ok && sub == nil {
+		// the whole field is already projected
+		return nil
+	}
+	if sub == nil {
+		md, err := relatedModelDescription(fd)
+		if err != nil {
+			return err
+		}
+		sub = NewProjection(md)
+		p.fields[fd.Idx] = sub
+	}
+	return sub.addPath(path[1:])
+}
+
+// field returns nested projection of fd and ok if fd is projected, nil projection contains all fields
+func (p *Projection) field(fd *FieldDescription) (*Projection, bool) {
+	if p == nil {
+		return nil, true
+	}
+	sub, ok := p.fields[fd.Idx]
+	return sub, ok
+}
+
+func (p *Projection) Has(fd *FieldDescription) bool {
+	_, ok := p.field(fd)
+	return ok
+}
+
+// Nested returns projection of related model for fd, nil if fd is projected as a whole
+func (p *Projection) Nested(fd *FieldDescription) *Projection {
+	sub, _ := p.field(fd)
+	return sub
+}
+
+func (p *Projection) FieldDescriptions() []*FieldDescription {
+	res := make([]*FieldDescription, 0, len(p.fields))
+	for idx := range p.fields {
+		res = append(res, p.md.ColumnPtrs[idx])
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Idx < res[j].Idx })
+	return res
+}
+
+func (p *Projection) String() string {
+	var paths []string
+	for _, fd := range p.FieldDescriptions() {
+		if sub := p.fields[fd.Idx]; sub != nil {
+			for _, s := range strings.Split(sub.String(), ",") {
+				paths = append(paths, fd.JsonName+"."+s)
+			}
+		} else {
+			paths = append(paths, fd.JsonName)
+		}
+	}
+	return strings.Join(paths, ",")
+}
+
+// filterJSON applies projection to generic json value of related model
+func (p *Projection) filterJSON(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(p.fields))
+		for k, fv := range vv {
+			fd, ok := p.md.ColumnByJsonName[k]
+			if !ok {
+				continue
+			}
+			if sub, ok := p.fields[fd.Idx]; ok {
+				if sub != nil {
+					fv = sub.filterJSON(fv)
+				}
+				res[k] = fv
+			}
+		}
+		return res
+	case []interface{}:
+		for i := range vv {
+			vv[i] = p.filterJSON(vv[i])
+		}
+	}
+	return v
+}
+
+func writeProjectedJSON(b *bytes.Buffer, enc *json.Encoder, v interface{}, p *Projection) error {
+	switch vv := v.(type) {
+	case ModelObject:
+		return vv.writeJSON(b, p)
+	case *ModelObject:
+		if vv == nil {
+			b.WriteString("null")
+			return nil
+		}
+		return vv.writeJSON(b, p)
+	case []ModelObject:
+		b.WriteByte('[')
+		for i := range vv {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := vv[i].writeJSON(b, p); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+		return nil
+	}
+	gv, err := jsonValue(v)
+	if err != nil {
+		return err
+	}
+	if err := enc.Encode(p.filterJSON(gv)); err != nil {
+		return err
+	}
+	b.Truncate(b.Len() - 1) // Encode appends newline
+	return nil
+}
+
+// ProjectedObject encodes model object with projection without copying
+type ProjectedObject struct {
+	mo ModelObject
+	p  *Projection
+}
+
+func (mo ModelObject) Project(p *Projection) ProjectedObject {
+	return ProjectedObject{
+		mo: mo,
+		p:  p,
+	}
+}
+
+func (po ProjectedObject) MarshalJSON() ([]byte, error) {
+	return po.mo.MarshalJSONProjection(po.p)
+}
+
+var relatedDescriptions sync.Map // reflect.Type -> *ModelDescription
+
+var storableType = reflect.TypeOf((*Storable)(nil)).Elem()
+
+// relatedModelDescription returns description of model type of relation field
+func relatedModelDescription(fd *FieldDescription) (*ModelDescription, error) {
+	typ := fd.ElemType
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if fd.Relation.Type == RelationTypeNotRelation || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("field %s is not a relation", fd.JsonName)
+	}
+	if md, ok := relatedDescriptions.Load(typ); ok {
+		return md.(*ModelDescription), nil
+	}
+
+	var s Storable
+	if typ.Implements(storableType) {
+		s = reflect.Zero(typ).Interface().(Storable)
+	} else if reflect.PtrTo(typ).Implements(storableType) {
+		s = reflect.New(typ).Interface().(Storable)
+	} else {
+		return nil, fmt.Errorf("type %s of field %s is not storable", typ, fd.JsonName)
+	}
+	md, err := NewModelDescription(typ, s.StoreName())
+	if err != nil {
+		return nil, err
+	}
+	relatedDescriptions.Store(typ, md)
+	return md, nil
+}
@@ -2,6 +2,8 @@ package inmemdb
 
 import (
 	"fmt"
+	"sort"
+	"time"
 )
 
 type ModelSortable interface {
@@ -15,163 +17,357 @@ type KV struct {
 }
 
 type ModelIndex struct {
-	kvs []KV
+	kvs       btree[KV] // sorted by K, then by V
+	buildTime time.Duration
 }
 
+func kvLess(a, b KV) bool {
+	return SortableLess(a.K, b.K) || (SortableEqual(a.K, b.K) && a.V.ModelLess(b.V))
+}
+
+// newSortedIndex creates index of entries in any order by sorting them and bulk loading of B-tree
+func newSortedIndex(kvs []KV) *ModelIndex {
+	sort.Slice(kvs, func(i, j int) bool { return kvLess(kvs[i], kvs[j]) })
+	mi := &ModelIndex{}
+	mi.kvs.load(kvs)
+	return mi
+}
+
+// NewModelIndex creates empty index, capacity is not used since index is a B-tree
 func NewModelIndex(capacity int) *ModelIndex {
-	return &ModelIndex{
-		kvs: make([]KV, 0, capacity),
-	}
+	return &ModelIndex{}
 }
 
-func searchKV(a []KV, x KV, i, n uint32) uint32 {
-	if n > 0 {
-		j := n
-		for i < j {
-			h := (i + j) >> 1
-			if a[h].K.ModelLess(x.K) || (a[h].K.ModelEqual(x.K) && a[h].V.ModelLess(x.V)) {
-				i = h + 1
-			} else {
-				j = h
-			}
-		}
-	}
-	return i
+// searchKV returns position of first entry that is not less than x
+func (mi *ModelIndex) searchKV(x KV) int {
+	return mi.kvs.Search(func(kv KV) bool {
+		return !kvLess(kv, x)
+	})
 }
 
-func searchK(a []KV, x ModelSortable, i, n uint32) uint32 {
-	if n > 0 {
-		j := n
-		for i < j {
-			h := (i + j) >> 1
-			if a[h].K.ModelLess(x) {
-				i = h + 1
-			} else {
-				j = h
-			}
-		}
-	}
-	return i
+// searchK returns position of first entry with key that is not less than x
+func (mi *ModelIndex) searchK(x ModelSortable) int {
+	return mi.kvs.Search(func(kv KV) bool {
+		return !SortableLess(kv.K, x)
+	})
 }
 
 func (mi *ModelIndex) Insert(kv KV) {
-	ln := uint32(len(mi.kvs))
-	idx := searchKV(mi.kvs, kv, 0, ln)
-	mi.kvs = append(mi.kvs, kv)
-	if idx < ln {
-		copy(mi.kvs[idx+1:], mi.kvs[idx:])
-		mi.kvs[idx] = kv
-	}
+	mi.kvs.Insert(mi.searchKV(kv), kv)
 }
 
 func (mi *ModelIndex) Delete(kv KV) {
-	ln := uint32(len(mi.kvs))
-	idx := searchKV(mi.kvs, kv, 0, ln)
-	if idx < ln && mi.kvs[idx].K.ModelEqual(kv.K) && mi.kvs[idx].V.ModelEqual(kv.V) {
-		copy(mi.kvs[idx:], mi.kvs[idx+1:])
-		mi.kvs = mi.kvs[:len(mi.kvs)-1]
+	idx := mi.searchKV(kv)
+	if idx < mi.kvs.Len() {
+		if ikv := mi.kvs.At(idx); SortableEqual(ikv.K, kv.K) && ikv.V.ModelEqual(kv.V) {
+			mi.kvs.Delete(idx)
+		}
 	}
 }
 
 func (mi *ModelIndex) DeleteAllForKey(kk ModelSortable) {
-	ln := uint32(len(mi.kvs))
-	idxl := searchK(mi.kvs, kk, 0, ln)
-	if idxl < ln && mi.kvs[idxl].K.ModelEqual(kk) {
-		lndel := uint32(1)
-		for idxl+lndel < ln && mi.kvs[idxl+lndel].K.ModelEqual(kk) {
-			lndel++
-		}
-		if idxl+lndel <= ln {
-			copy(mi.kvs[idxl:], mi.kvs[idxl+lndel:])
-			mi.kvs = mi.kvs[:len(mi.kvs)-int(lndel)]
-		}
+	l, r := mi.keyRange(kk)
+	for ; r > l; r-- {
+		mi.kvs.Delete(l)
 	}
 }
 
 // IterColumner interface
-func (mi *ModelIndex) Key(i int) ModelSortable { return mi.kvs[i].K }
-func (mi *ModelIndex) Len() int                { return len(mi.kvs) }
+func (mi *ModelIndex) Key(i int) ModelSortable { return mi.kvs.At(i).K }
+func (mi *ModelIndex) Len() int                { return mi.kvs.Len() }
+
+// indexIDs is IterColumner over IdField values of index entries with equal keys
+type indexIDs struct {
+	kvs    *btree[KV]
+	off, n int
+}
+
+func (s indexIDs) Key(i int) ModelSortable { return s.kvs.At(s.off + i).V }
+func (s indexIDs) Len() int                { return s.n }
+
+func (mi *ModelIndex) keyRange(k ModelSortable) (int, int) {
+	l := mi.searchK(k)
+	r := l
+	mi.kvs.Ascend(l, func(kv KV) bool {
+		if !SortableEqual(kv.K, k) {
+			return false
+		}
+		r++
+		return true
+	})
+	return l, r
+}
+
+// IDs returns iterator over ids of rows with key equal to k, in ascending order
+func (mi *ModelIndex) IDs(k ModelSortable) *ColumnIterator {
+	l, r := mi.keyRange(k)
+	return NewColumnIterator(indexIDs{kvs: &mi.kvs, off: l, n: r - l}, nil)
+}
+
+// NullIDs is IS NULL iterator, NULL keys are placed at the start of index
+func (mi *ModelIndex) NullIDs() *ColumnIterator {
+	return mi.IDs(Null)
+}
+
+// NotNullIDs is IS NOT NULL iterator
+func (mi *ModelIndex) NotNullIDs() *ColumnIterator {
+	_, r := mi.keyRange(Null)
+	ids := make(SortableList, 0, mi.kvs.Len()-r)
+	mi.kvs.Ascend(r, func(kv KV) bool {
+		ids = append(ids, kv.V)
+		return true
+	})
+	sort.Sort(ids)
+	return NewColumnIterator(ids, nil)
+}
+
+// Where returns iterator over ids of rows with key matched by op and k in ascending order,
+// comparisons with NULL don't match like in Compare3
+func (mi *ModelIndex) Where(op CompareOp, k ModelSortable) *ColumnIterator {
+	if IsNull(k) {
+		return NewColumnIterator(SortableList(nil), nil)
+	}
+	if op == OpEq {
+		return mi.IDs(k)
+	}
+	_, first := mi.keyRange(Null)
+	l, r := mi.keyRange(k)
+	var ranges [][2]int
+	switch op {
+	case OpNe:
+		ranges = [][2]int{{first, l}, {r, mi.kvs.Len()}}
+	case OpLt:
+		ranges = [][2]int{{first, l}}
+	case OpLe:
+		ranges = [][2]int{{first, r}}
+	case OpGt:
+		ranges = [][2]int{{r, mi.kvs.Len()}}
+	case OpGe:
+		ranges = [][2]int{{l, mi.kvs.Len()}}
+	}
+	var ids SortableList
+	for _, rg := range ranges {
+		i := rg[0]
+		mi.kvs.Ascend(rg[0], func(kv KV) bool {
+			if i >= rg[1] {
+				return false
+			}
+			ids = append(ids, kv.V)
+			i++
+			return true
+		})
+	}
+	sort.Sort(ids)
+	return NewColumnIterator(ids, nil)
+}
 
 type ModelTable struct {
-	md   *ModelDescription
-	t    []ModelObject // sorted by IdField ascending, that must implements ModelSortable
-	idxs []*ModelIndex // index in slice is index of field in md.ColumnPtrs, that values must implements ModelSortable
+	md       *ModelDescription
+	rows     rowStore      // sorted by IdField ascending, that must implements ModelSortable
+	idxs     []*ModelIndex // index in slice is index of field in md.ColumnPtrs, that values must implements ModelSortable
+	indexers []RowIndexer  // attached secondary indexes
 }
 
 func NewModelTable(md *ModelDescription, capacity int) *ModelTable {
 	mt := &ModelTable{
 		md:   md,
-		t:    make([]ModelObject, 0, capacity),
+		rows: newObjectStore(md),
 		idxs: make([]*ModelIndex, len(md.ColumnPtrs)),
 	}
 	return mt
 }
 
-func (mt *ModelTable) searchMO(x ModelSortable, fIdx, i, n uint32) uint32 {
-	if n > 0 {
-		j := n
-		for i < j {
-			h := (i + j) >> 1
-			if mt.t[h].v[fIdx].(ModelSortable).ModelLess(x) {
-				i = h + 1
-			} else {
-				j = h
-			}
-		}
+// NewColumnarModelTable creates table that keeps stored fields in typed columns instead of model objects.
+// It uses much less memory for large tables, but Get returns a view of the row that reads fields
+// from columns on first access, values of the row are kept for its views when the row is changed or deleted.
+// Changes of returned object must be saved by Upsert. Relation fields are not stored.
+func NewColumnarModelTable(md *ModelDescription, capacity int) *ModelTable {
+	mt := &ModelTable{
+		md:   md,
+		rows: newColumnStore(md, capacity),
+		idxs: make([]*ModelIndex, len(md.ColumnPtrs)),
+	}
+	return mt
+}
+
+func (mt *ModelTable) MD() *ModelDescription {
+	return mt.md
+}
+
+func (mt *ModelTable) search(id ModelSortable) (int, bool) {
+	i := mt.rows.search(id)
+	return i, i < mt.rows.len() && id.ModelEqual(mt.rows.id(i))
+}
+
+// rowID returns IdField value of model object, it must be not NULL and must implements ModelSortable
+func (mt *ModelTable) rowID(mo ModelObject) (ModelSortable, error) {
+	fd := mt.md.IdField
+	v := mo.v[fd.Idx]
+	if IsNull(v) {
+		return nil, ErrorField{Type: mt.md.ModelType, Field: fd.Name, Err: ErrConstraint}
+	}
+	smo, ok := v.(ModelSortable)
+	if !ok {
+		return nil, ErrorField{Type: mt.md.ModelType, Field: fd.Name, Value: v, Err: ErrNotSortable}
 	}
-	return i
+	return smo, nil
 }
 
 func (mt *ModelTable) Upsert(mo ModelObject) error {
 	if mo.md != mt.md {
-		return fmt.Errorf("model description for model object is not equal to model table model object")
+		return fmt.Errorf("%w: model description for model object is not equal to model table model object", ErrConstraint)
 	}
-	idIdx := uint32(mt.md.IdField.Idx)
-	smo, ok := mo.v[idIdx].(ModelSortable)
-	if !ok {
-		return fmt.Errorf("model object not implements sortable interface")
+	mo.Load()
+	smo, err := mt.rowID(mo)
+	if err != nil {
+		return err
 	}
-	ln := uint32(len(mt.t))
-	idx := mt.searchMO(smo, idIdx, 0, ln)
-	if idx == ln || !smo.ModelEqual(mt.t[idx].v[idIdx].(ModelSortable)) {
-		mt.t = append(mt.t, mo)
-		if idx < ln {
-			copy(mt.t[idx+1:], mt.t[idx:])
-			mt.t[idx] = mo
+	// all keys are checked before any changes
+	var keys []ModelSortable
+	for imi, mi := range mt.idxs {
+		if mi == nil {
+			continue
+		}
+		if keys == nil {
+			keys = make([]ModelSortable, len(mt.idxs))
+		}
+		fd := mt.md.ColumnPtrs[imi]
+		k, err := indexKey(mt.md, fd, mo.v[fd.Idx])
+		if err != nil {
+			return err
 		}
+		keys[imi] = k
+	}
+	idx, found := mt.search(smo)
+	var old *ModelObject
+	var oldKeys []ModelSortable
+	if found {
+		if len(mt.indexers) > 0 {
+			omo := mt.rows.row(idx)
+			old = &omo
+		}
+		oldKeys = mt.rowKeys(idx)
+		err = mt.rows.replace(idx, mo)
 	} else {
-		for imi, mi := range mt.idxs {
-			if mi == nil {
-				continue
-			}
-			mi.Delete(KV{
-				K: mt.t[idx].v[mt.md.ColumnPtrs[imi].Idx].(ModelSortable),
-				V: smo,
-			})
+		err = mt.rows.insert(idx, mo)
+	}
+	if err != nil {
+		return err
+	}
+	// row is already stored, so indexers can get its row number
+	if err := mt.indexRow(smo, old, mo); err != nil {
+		if found {
+			mt.rows.replace(idx, *old)
+		} else {
+			mt.rows.remove(idx)
 		}
-		mt.t[idx] = mo
+		return err
+	}
+	if found {
+		mt.deleteIndexes(oldKeys, smo)
 	}
 	for imi, mi := range mt.idxs {
 		if mi == nil {
 			continue
 		}
 		mi.Insert(KV{
-			K: mo.v[mt.md.ColumnPtrs[imi].Idx].(ModelSortable),
+			K: keys[imi],
 			V: smo,
 		})
 	}
 	return nil
 }
 
-func (mt *ModelTable) CreateIndex(fd *FieldDescription) *ModelIndex {
-	mi := NewModelIndex(cap(mt.t))
-	for _, mo := range mt.t {
-		mi.Insert(KV{
-			K: mo.v[fd.Idx].(ModelSortable),
-			V: mo.v[mo.md.IdField.Idx].(ModelSortable),
+// Delete removes row with id, returns ErrNotFound if there is no such row
+func (mt *ModelTable) Delete(id ModelSortable) error {
+	idx, found := mt.search(id)
+	if !found {
+		return ErrorField{Type: mt.md.ModelType, Field: mt.md.IdField.Name, Value: id, Err: ErrNotFound}
+	}
+	mt.deleteIndexes(mt.rowKeys(idx), id)
+	if len(mt.indexers) > 0 {
+		mo := mt.rows.row(idx)
+		for _, ri := range mt.indexers {
+			ri.UnindexRow(id, mo)
+		}
+	}
+	mt.rows.remove(idx)
+	return nil
+}
+
+// rowKeys returns index keys of stored row, keys of stored rows are already checked by Upsert
+func (mt *ModelTable) rowKeys(i int) []ModelSortable {
+	var keys []ModelSortable
+	for imi, mi := range mt.idxs {
+		if mi == nil {
+			continue
+		}
+		if keys == nil {
+			keys = make([]ModelSortable, len(mt.idxs))
+		}
+		fd := mt.md.ColumnPtrs[imi]
+		keys[imi], _ = indexKey(mt.md, fd, mt.rows.field(i, fd))
+	}
+	return keys
+}
+
+func (mt *ModelTable) deleteIndexes(keys []ModelSortable, id ModelSortable) {
+	for imi, mi := range mt.idxs {
+		if mi == nil {
+			continue
+		}
+		mi.Delete(KV{
+			K: keys[imi],
+			V: id,
 		})
 	}
+}
+
+// indexKey returns value of field for ModelIndex, NULL values are stored as Null
+func indexKey(md *ModelDescription, fd *FieldDescription, v interface{}) (ModelSortable, error) {
+	k, ok := ToSortable(v)
+	if !ok {
+		return nil, ErrorField{Type: md.ModelType, Field: fd.Name, Value: v, Err: ErrNotSortable}
+	}
+	return k, nil
+}
+
+// CreateIndex builds index of field fd by sorting of all entries
+func (mt *ModelTable) CreateIndex(fd *FieldDescription) (*ModelIndex, error) {
+	start := time.Now()
+	kvs, err := mt.indexKVs(fd)
+	if err != nil {
+		return nil, err
+	}
+	mi := newSortedIndex(kvs)
+	mi.buildTime = time.Since(start)
 	mt.idxs[fd.Idx] = mi
+	return mi, nil
+}
+
+// indexKVs returns unsorted index entries of field fd for all rows
+func (mt *ModelTable) indexKVs(fd *FieldDescription) ([]KV, error) {
+	ln := mt.rows.len()
+	kvs := make([]KV, ln)
+	for i := 0; i < ln; i++ {
+		k, err := indexKey(mt.md, fd, mt.rows.field(i, fd))
+		if err != nil {
+			return nil, err
+		}
+		kvs[i] = KV{
+			K: k,
+			V: mt.rows.id(i),
+		}
+	}
+	return kvs, nil
+}
+
+// MustCreateIndex is like CreateIndex but panics on error
+func (mt *ModelTable) MustCreateIndex(fd *FieldDescription) *ModelIndex {
+	mi, err := mt.CreateIndex(fd)
+	if err != nil {
+		panic(err)
+	}
 	return mi
 }
 
@@ -183,28 +379,91 @@ func (mt *ModelTable) HasIndex(fd *FieldDescription) bool {
 	return mt.idxs[fd.Idx] != nil
 }
 
-// IterColumner interface
-func (mt *ModelTable) Key(i int) ModelSortable { return mt.t[i].IDField().(ModelSortable) }
-func (mt *ModelTable) Len() int                { return len(mt.t) }
+// IterColumner interface, ids are checked by Upsert, so Key panics only on wrong index
+func (mt *ModelTable) Key(i int) ModelSortable { return mt.rows.id(i) }
+func (mt *ModelTable) Len() int                { return mt.rows.len() }
+
+// KeyAt returns id of row at position i
+func (mt *ModelTable) KeyAt(i int) (ModelSortable, error) {
+	if i < 0 || i >= mt.rows.len() {
+		return nil, ErrorField{Type: mt.md.ModelType, Field: mt.md.IdField.Name, Value: i, Err: ErrNotFound}
+	}
+	return mt.rows.id(i), nil
+}
+
+func (mt *ModelTable) Get(id ModelSortable) (ModelObject, bool) {
+	idx, found := mt.search(id)
+	if !found {
+		return ModelObject{}, false
+	}
+	return mt.rows.row(idx), true
+}
+
+// IsNull returns iterator over ids of rows where field value IS NULL
+func (mt *ModelTable) IsNull(fd *FieldDescription) IDIterator {
+	if mi := mt.idxs[fd.Idx]; mi != nil {
+		return mi.NullIDs()
+	}
+	return mt.scan(func(i int) bool {
+		return IsNull(mt.rows.field(i, fd))
+	})
+}
+
+// IsNotNull returns iterator over ids of rows where field value IS NOT NULL
+func (mt *ModelTable) IsNotNull(fd *FieldDescription) IDIterator {
+	if mi := mt.idxs[fd.Idx]; mi != nil {
+		return mi.NotNullIDs()
+	}
+	return mt.scan(func(i int) bool {
+		return !IsNull(mt.rows.field(i, fd))
+	})
+}
+
+// Where returns iterator over ids of rows where comparison "field op value" is TRUE,
+// rows with UNKNOWN result (NULL field or value) are skipped
+func (mt *ModelTable) Where(fd *FieldDescription, op CompareOp, value ModelSortable) (IDIterator, error) {
+	if !IsSortableType(fd.StructField.Type) {
+		return nil, ErrorField{Type: mt.md.ModelType, Field: fd.Name, Err: ErrNotSortable}
+	}
+	if op == OpEq && !IsNull(value) {
+		if mi := mt.idxs[fd.Idx]; mi != nil {
+			return mi.IDs(value), nil
+		}
+		if hi := mt.HashIndex(fd); hi != nil {
+			return hi.IDs(value)
+		}
+	}
+	return mt.scan(func(i int) bool {
+		k, err := indexKey(mt.md, fd, mt.rows.field(i, fd))
+		return err == nil && Compare3(k, op, value).IsTrue()
+	}), nil
+}
+
+// scan iterates over all rows in id order and skips rows which positions are not matched by f
+func (mt *ModelTable) scan(f func(i int) bool) IDIterator {
+	return NewColumnIterator(mt, func(id ModelSortable) bool {
+		i, ok := mt.search(id)
+		return !ok || !f(i)
+	})
+}
 
 func (mt *ModelTable) MarshalJSON() ([]byte, error) {
 	b := GetBuffer()
-	b.Grow(len(mt.t) * 128)
+	b.Grow(mt.rows.len() * 128)
 	defer PutBuffer(b)
 
 	b.WriteByte('[')
-	for i, mo := range mt.t {
+	for i := 0; i < mt.rows.len(); i++ {
 		if i > 0 {
 			b.WriteByte(',')
 		}
-		bel, err := mo.MarshalJSON()
-		if err != nil {
+		if err := mt.rows.row(i).writeJSON(b, nil); err != nil {
 			return nil, err
 		}
-		b.Write(bel)
 	}
 	b.WriteByte(']')
-	res := b.Bytes()
+	res := make([]byte, b.Len())
+	copy(res, b.Bytes())
 
 	return res, nil
 }
@@ -0,0 +1,285 @@
+package inmemdb
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type TestErrMO struct {
+	ID    UUIDv4
+	Name  String
+	Count int
+}
+
+func (t TestErrMO) StoreName() string { return "testerrmo" }
+
+func TestModelTableErrors(t *testing.T) {
+	tt := TestErrMO{}
+	md, _ := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	fds, err := md.GetColumnsByFieldNames("Name", "Count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	namefd, countfd := fds[0], fds[1]
+
+	if _, err := md.GetColumnsByFieldNames("Name", "Unknown"); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("expected ErrUnknownField, got %v", err)
+	}
+
+	mt := NewModelTable(md, 10)
+
+	mo := NewModelObject(md)
+	if err := mt.Upsert(mo); !errors.Is(err, ErrConstraint) {
+		t.Errorf("expected ErrConstraint for NULL id, got %v", err)
+	}
+
+	id := NewV4()
+	mo.SetIDField(id)
+	mo.SetField(namefd, "name")
+	mo.SetField(countfd, 1)
+	if err := mt.Upsert(mo); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mt.CreateIndex(countfd)
+	var ferr ErrorField
+	if !errors.As(err, &ferr) || ferr.Err != ErrNotSortable || ferr.Field != countfd.Name {
+		t.Errorf("expected ErrNotSortable for field %s, got %v", countfd.Name, err)
+	}
+	if mt.HasIndex(countfd) {
+		t.Error("index must not be created")
+	}
+	if _, err := mt.Where(countfd, OpEq, String("1")); !errors.Is(err, ErrNotSortable) {
+		t.Errorf("expected ErrNotSortable, got %v", err)
+	}
+
+	mt.MustCreateIndex(namefd)
+	if err := mt.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.Delete(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if mt.Len() != 0 || mt.idxs[namefd.Idx].Len() != 0 {
+		t.Error("row must be deleted from table and indexes")
+	}
+}
+
+func TestModelTableJSONStream(t *testing.T) {
+	tt := TestObjMO{}
+	md, _ := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	mt := NewModelTable(md, 10)
+
+	id1, id2 := NewV4(), NewV4()
+	src := `[{"id":"` + id1.String() + `","name":"a","count":1},
+		{"id":"bad","name":"b"},
+		{"id":"` + id2.String() + `","name":"c","note":null}]`
+	n, err := mt.DecodeJSON(strings.NewReader(src))
+	var rowErrs ErrorRows
+	if n != 2 || !errors.As(err, &rowErrs) || len(rowErrs) != 1 || rowErrs[0].Row != 2 {
+		t.Fatalf("wrong decode result: %d %v", n, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := mt.EncodeNDJSON(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 {
+		t.Fatalf("wrong ndjson: %s", buf.String())
+	}
+
+	mt2 := NewModelTable(md, 10)
+	if n, err := mt2.DecodeJSON(buf); n != 2 || err != nil {
+		t.Fatalf("wrong ndjson decode result: %d %v", n, err)
+	}
+	b1, _ := mt.MarshalJSON()
+	buf.Reset()
+	if err := mt2.EncodeJSON(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if string(b1) != buf.String() {
+		t.Errorf("tables are not equal:\n%s\n%s", b1, buf.String())
+	}
+}
+
+func TestModelTableCSV(t *testing.T) {
+	tt := TestObjMO{}
+	md, _ := NewModelDescription(reflect.TypeOf(tt), tt.StoreName())
+	mt := NewModelTable(md, 10)
+
+	id1, id2 := NewV4(), NewV4()
+	src := "id,name,note,count,extra\n" +
+		id1.String() + ",a,,1,x\n" +
+		id2.String() + ",b,note,x,x\n" +
+		id2.String() + ",\"b,c\",note,2,x\n"
+	n, err := mt.DecodeCSV(strings.NewReader(src))
+	var rowErrs ErrorRows
+	if n != 2 || !errors.As(err, &rowErrs) || len(rowErrs) != 1 || rowErrs[0].Row != 3 {
+		t.Fatalf("wrong decode result: %d %v", n, err)
+	}
+	mo, _ := mt.Get(id1)
+	if mo.Field(md.ColumnByName["note"]) != Null || mo.Field(md.ColumnByName["count"]) != 1 {
+		t.Errorf("wrong row: %v", mo)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := mt.EncodeCSV(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	mt2 := NewModelTable(md, 10)
+	if n, err := mt2.DecodeCSV(bytes.NewReader(buf.Bytes())); n != 2 || err != nil {
+		t.Fatalf("wrong decode result: %d %v", n, err)
+	}
+	buf2 := &bytes.Buffer{}
+	mt2.EncodeCSV(buf2, nil)
+	if buf.String() != buf2.String() {
+		t.Errorf("tables are not equal:\n%s\n%s", buf, buf2)
+	}
+}
+
+func TestColumnarModelTable(t *testing.T) {
+	md, mo := newTestObj(t)
+	fds := md.MustGetColumnsByFieldNames("Name", "Note", "Count")
+	namefd, notefd, countfd := fds[0], fds[1], fds[2]
+
+	mt := NewModelTable(md, 10)
+	ct := NewColumnarModelTable(md, 10)
+	ct.MustCreateIndex(namefd)
+
+	ids := []UUIDv4{mo.IDField().(UUIDv4), NewV4(), NewV4()}
+	for i, id := range ids {
+		row := NewModelObject(md)
+		row.SetIDField(id)
+		row.SetField(namefd, []string{"a", "b", "a"}[i])
+		switch i {
+		case 0:
+			row.SetField(notefd, "note")
+			row.SetField(countfd, 1)
+		case 1:
+			row.SetField(notefd, Null)
+		}
+		if err := mt.Upsert(row); err != nil {
+			t.Fatal(err)
+		}
+		if err := ct.Upsert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b1, _ := mt.MarshalJSON()
+	b2, _ := ct.MarshalJSON()
+	if string(b1) != string(b2) {
+		t.Errorf("tables are not equal:\n%s\n%s", b1, b2)
+	}
+
+	got, ok := ct.Get(ids[1])
+	if !ok || got.Field(notefd) != Null || got.Field(countfd) != nil {
+		t.Errorf("null and absent fields must be preserved: %v", got)
+	}
+	got, _ = ct.Get(ids[0])
+	if note, ok := got.Field(notefd).(*String); !ok || *note != "note" {
+		t.Errorf("wrong pointer field: %#v", got.Field(notefd))
+	}
+
+	it, err := ct.Where(namefd, OpEq, String("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(collectIDs(it)); n != 2 {
+		t.Errorf("expected 2 rows, got %d", n)
+	}
+	if n := len(collectIDs(ct.IsNull(notefd))); n != 2 {
+		t.Errorf("expected 2 null rows, got %d", n)
+	}
+
+	bad := NewModelObject(md)
+	bad.SetIDField(ids[0])
+	bad.v[countfd.Idx] = "not a number"
+	if err := ct.Upsert(bad); err == nil {
+		t.Error("expected conversion error")
+	}
+	if got, _ := ct.Get(ids[0]); got.Field(countfd) != 1 {
+		t.Errorf("row must not be changed on error: %v", got)
+	}
+
+	if err := ct.Delete(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ct.Upsert(mo); err != nil {
+		t.Fatal(err)
+	}
+	if ct.Len() != 2 || ct.idxs[namefd.Idx].Len() != 2 {
+		t.Errorf("wrong table size: %d", ct.Len())
+	}
+	if got, _ := ct.Get(ids[0]); got.Field(namefd) != String("name") {
+		t.Errorf("row must be replaced: %v", got)
+	}
+
+	// ids are not boxed on search
+	var id ModelSortable = ids[2]
+	if n := testing.AllocsPerRun(100, func() {
+		ct.search(id)
+		ct.Key(1)
+	}); n != 0 {
+		t.Errorf("search allocates %v times", n)
+	}
+
+	view, _ := ct.Get(ids[2])
+	loaded, _ := ct.Get(ids[2])
+	loaded.Load()
+	if view.Field(namefd) != String("a") {
+		t.Errorf("wrong field of row view: %v", view)
+	}
+	upd := NewModelObject(md)
+	upd.SetIDField(ids[2])
+	upd.SetField(namefd, "c")
+	if err := ct.Upsert(upd); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Field(namefd) != String("a") {
+		t.Errorf("loaded row must keep values: %v", loaded)
+	}
+	// views of changed and deleted rows return values they were created with
+	if view.Field(countfd) != nil || view.Field(namefd) != String("a") {
+		t.Errorf("view of changed row must keep values: %v", view)
+	}
+	deleted, _ := ct.Get(ids[2])
+	if err := ct.Delete(ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	// slot of deleted row is reused
+	reused := NewModelObject(md)
+	reused.SetIDField(NewV4())
+	reused.SetField(namefd, "d")
+	if err := ct.Upsert(reused); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Field(namefd) != String("c") || !deleted.IDField().(UUIDv4).ModelEqual(ids[2]) {
+		t.Errorf("view of deleted row must keep values: %v", deleted)
+	}
+}
+
+// forEachStore runs test with empty tables of T with object and columnar storage
+func forEachStore[T Storable](t *testing.T, test func(t *testing.T, tbl *Table[T])) {
+	run := func(name string, newTable func(int) (*Table[T], error)) {
+		t.Run(name, func(t *testing.T) {
+			tbl, err := newTable(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, tbl)
+		})
+	}
+	run("objects", NewTable[T])
+	run("columns", NewColumnarTable[T])
+}
+
+func collectIDs(it IDIterator) (res []ModelSortable) {
+	for it.HasNext() {
+		res = append(res, it.NextID())
+	}
+	return
+}
@@ -0,0 +1,265 @@
+package inmemdb
+
+import (
+	"bufio"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	converterType       = reflect.TypeOf((*Converter)(nil)).Elem()
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// csvTimeLayouts are layouts for parsing time cells, RFC3339 is used for export
+var csvTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// EncodeCSV writes stored fields of rows with ids from iter as CSV with store names as headers,
+// all rows are written if iter is nil. NULL values are written as empty cells.
+func (mt *ModelTable) EncodeCSV(w io.Writer, iter IDIterator) error {
+	if iter == nil {
+		iter = NewColumnIterator(mt, nil)
+	}
+	fds := make([]*FieldDescription, 0, len(mt.md.ColumnPtrs))
+	for _, fd := range mt.md.ColumnPtrs {
+		if fd.IsStored() {
+			fds = append(fds, fd)
+		}
+	}
+
+	cw := csv.NewWriter(bufio.NewWriterSize(w, streamBufferSize))
+	if err := cw.Write(mt.md.GetStoredColumnNames()); err != nil {
+		return err
+	}
+	rec := make([]string, len(fds))
+	for iter.HasNext() {
+		mo, ok := mt.Get(iter.NextID())
+		if !ok {
+			continue
+		}
+		for i, fd := range fds {
+			s, err := formatCSVValue(mo.val(fd.Idx))
+			if err != nil {
+				return fmt.Errorf("can't format field %s: %w", fd.Name, err)
+			}
+			rec[i] = s
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSVValue(v interface{}) (string, error) {
+	if IsNull(v) {
+		return "", nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		v = rv.Elem().Interface()
+	}
+	switch vv := v.(type) {
+	case string:
+		return vv, nil
+	case []byte:
+		return string(vv), nil
+	case time.Time:
+		return vv.Format(time.RFC3339Nano), nil
+	case encoding.TextMarshaler:
+		b, err := vv.MarshalText()
+		return string(b), err
+	case driver.Valuer:
+		dv, err := vv.Value()
+		if err != nil {
+			return "", err
+		}
+		if _, ok := dv.(driver.Valuer); ok {
+			return "", fmt.Errorf("driver value of %T is a valuer", v)
+		}
+		return formatCSVValue(dv)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// DecodeCSV reads CSV with header and upserts rows into the table.
+// Headers are mapped to fields by store names or json names, unknown columns are skipped.
+// Empty cells of nullable fields become NULL. Invalid rows are skipped and returned
+// as ErrorRows with line numbers, the other rows are loaded. Returns the number of loaded rows.
+func (mt *ModelTable) DecodeCSV(r io.Reader) (int, error) {
+	cr := csv.NewReader(bufio.NewReaderSize(r, streamBufferSize))
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	fds := make([]*FieldDescription, len(header))
+	for i, h := range header {
+		fd, ok := mt.md.ColumnByName[h]
+		if !ok {
+			fd, ok = mt.md.ColumnByJsonName[h]
+		}
+		if ok && fd.IsStored() {
+			fds[i] = fd
+		}
+	}
+
+	var rowErrs ErrorRows
+	n := 0
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			rowErrs = append(rowErrs, ErrorRow{Row: perr.StartLine, Err: perr.Err})
+			continue
+		} else if err != nil {
+			return n, err
+		}
+		line, _ := cr.FieldPos(0)
+		if err := mt.decodeCSVRow(fds, rec); err != nil {
+			rowErrs = append(rowErrs, ErrorRow{Row: line, Err: err})
+			continue
+		}
+		n++
+	}
+
+	if len(rowErrs) > 0 {
+		return n, rowErrs
+	}
+	return n, nil
+}
+
+func (mt *ModelTable) decodeCSVRow(fds []*FieldDescription, rec []string) error {
+	mo := NewModelObject(mt.md)
+	var err error
+	for i, fd := range fds {
+		if fd == nil {
+			continue
+		}
+		var v interface{}
+		if v, err = convertCSVValue(rec[i], fd); err != nil {
+			err = fmt.Errorf("can't convert column %s to %s: %w", fd.Name, fd.StructField.Type, err)
+			break
+		}
+		mo.v[fd.Idx] = v
+	}
+	if err == nil {
+		err = mo.Validate()
+	}
+	if err == nil {
+		err = mt.Upsert(mo)
+	}
+	if err != nil {
+		mo.Close()
+	}
+	return err
+}
+
+// convertCSVValue parses cell by kind of field type and converts it with ConvertToType
+func convertCSVValue(s string, fd *FieldDescription) (interface{}, error) {
+	t := fd.StructField.Type
+	if s == "" && fd.Nullable {
+		return Null, nil
+	}
+	et := t
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	pt := reflect.PtrTo(et)
+
+	var v interface{} = s
+	switch {
+	case pt.Implements(converterType):
+	case pt.Implements(scannerType):
+		p := reflect.New(et)
+		if err := p.Interface().(sql.Scanner).Scan(s); err != nil {
+			return nil, err
+		}
+		v = p.Elem().Interface()
+	case pt.Implements(textUnmarshalerType):
+		p := reflect.New(et)
+		if err := p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return nil, err
+		}
+		v = p.Elem().Interface()
+	case s == "" && et.Kind() != reflect.String:
+		return reflect.Zero(t).Interface(), nil
+	default:
+		var err error
+		switch et.Kind() {
+		case reflect.Bool:
+			v, err = strconv.ParseBool(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v, err = strconv.ParseInt(s, 10, et.Bits())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err = strconv.ParseUint(s, 10, et.Bits())
+		case reflect.Float32, reflect.Float64:
+			v, err = strconv.ParseFloat(s, et.Bits())
+		case reflect.Struct:
+			if et == timeType {
+				v, err = parseCSVTime(s)
+			}
+		case reflect.Slice, reflect.Map:
+			if et.Kind() == reflect.Slice && et.Elem().Kind() == reflect.Uint8 {
+				v = []byte(s)
+				break
+			}
+			p := reflect.New(et)
+			err = json.Unmarshal([]byte(s), p.Interface())
+			v = p.Elem().Interface()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ConvertToType(v, t)
+}
+
+func parseCSVTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range csvTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
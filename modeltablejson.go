@@ -0,0 +1,143 @@
+package inmemdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+const streamBufferSize = 32 << 10
+
+// EncodeJSON writes rows with ids from iter as json array, all rows are written if iter is nil.
+// Rows are encoded one by one, so memory usage does not depend on the number of rows.
+func (mt *ModelTable) EncodeJSON(w io.Writer, iter IDIterator) error {
+	return mt.encodeJSON(w, iter, nil, false)
+}
+
+// EncodeNDJSON is like EncodeJSON, but writes newline delimited json objects
+func (mt *ModelTable) EncodeNDJSON(w io.Writer, iter IDIterator) error {
+	return mt.encodeJSON(w, iter, nil, true)
+}
+
+// EncodeJSONProjection is like EncodeJSON, but writes only fields of projection p
+func (mt *ModelTable) EncodeJSONProjection(w io.Writer, iter IDIterator, p *Projection) error {
+	return mt.encodeJSON(w, iter, p, false)
+}
+
+// EncodeNDJSONProjection is like EncodeNDJSON, but writes only fields of projection p
+func (mt *ModelTable) EncodeNDJSONProjection(w io.Writer, iter IDIterator, p *Projection) error {
+	return mt.encodeJSON(w, iter, p, true)
+}
+
+func (mt *ModelTable) encodeJSON(w io.Writer, iter IDIterator, p *Projection, ndjson bool) error {
+	if iter == nil {
+		iter = NewColumnIterator(mt, nil)
+	}
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+	b := GetBuffer()
+	defer PutBuffer(b)
+	if !ndjson {
+		bw.WriteByte('[')
+	}
+	first := true
+	for iter.HasNext() {
+		mo, ok := mt.Get(iter.NextID())
+		if !ok {
+			continue
+		}
+		b.Reset()
+		if err := mo.writeJSON(b, p); err != nil {
+			return err
+		}
+		if !ndjson && !first {
+			bw.WriteByte(',')
+		}
+		if _, err := b.WriteTo(bw); err != nil {
+			return err
+		}
+		if ndjson {
+			bw.WriteByte('\n')
+		}
+		first = false
+	}
+	if !ndjson {
+		bw.WriteByte(']')
+	}
+	return bw.Flush()
+}
+
+// DecodeJSON reads json array or newline delimited json objects and upserts them into the table.
+// Invalid rows are skipped and returned as ErrorRows, the other rows are loaded.
+// Syntax errors stop the loading. Returns the number of loaded rows.
+func (mt *ModelTable) DecodeJSON(r io.Reader) (int, error) {
+	br := bufio.NewReaderSize(r, streamBufferSize)
+	array, err := isJSONArray(br)
+	if err == io.EOF {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	dec := json.NewDecoder(br)
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return 0, err
+		}
+	}
+
+	var rowErrs ErrorRows
+	n, row := 0, 0
+	for !array || dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF && !array {
+			break
+		} else if err != nil {
+			return n, fmt.Errorf("row %d: %w", row+1, err)
+		}
+		row++
+		if err := mt.decodeRow(raw); err != nil {
+			rowErrs = append(rowErrs, ErrorRow{Row: row, Err: err})
+			continue
+		}
+		n++
+	}
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return n, err
+		}
+	}
+
+	if len(rowErrs) > 0 {
+		return n, rowErrs
+	}
+	return n, nil
+}
+
+func (mt *ModelTable) decodeRow(raw []byte) error {
+	mo := NewModelObject(mt.md)
+	err := mo.UnmarshalJSON(raw)
+	if err == nil {
+		err = mo.Validate()
+	}
+	if err == nil {
+		err = mt.Upsert(mo)
+	}
+	if err != nil {
+		mo.Close()
+	}
+	return err
+}
+
+func isJSONArray(br *bufio.Reader) (bool, error) {
+	for {
+		r, _, err := br.ReadRune()
+		if err != nil {
+			return false, err
+		}
+		if !unicode.IsSpace(r) {
+			return r == '[', br.UnreadRune()
+		}
+	}
+}
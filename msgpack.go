@@ -0,0 +1,409 @@
+package inmemdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MessagePack format primitives, see https://github.com/msgpack/msgpack/blob/master/spec.md
+
+const (
+	mpNil      = 0xc0
+	mpFalse    = 0xc2
+	mpTrue     = 0xc3
+	mpBin8     = 0xc4
+	mpBin16    = 0xc5
+	mpBin32    = 0xc6
+	mpExt8     = 0xc7
+	mpExt16    = 0xc8
+	mpExt32    = 0xc9
+	mpFloat32  = 0xca
+	mpFloat64  = 0xcb
+	mpUint8    = 0xcc
+	mpUint16   = 0xcd
+	mpUint32   = 0xce
+	mpUint64   = 0xcf
+	mpInt8     = 0xd0
+	mpInt16    = 0xd1
+	mpInt32    = 0xd2
+	mpInt64    = 0xd3
+	mpFixExt1  = 0xd4
+	mpFixExt4  = 0xd6
+	mpFixExt8  = 0xd7
+	mpFixExt16 = 0xd8
+	mpStr8     = 0xd9
+	mpStr16    = 0xda
+	mpStr32    = 0xdb
+	mpArray16  = 0xdc
+	mpArray32  = 0xdd
+	mpMap16    = 0xde
+	mpMap32    = 0xdf
+
+	mpExtTime = -1
+	mpExtJSON = 1 // values without native representation are encoded as json
+)
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+func mpAppendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func mpAppendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func mpAppendUint64(b []byte, v uint64) []byte {
+	return mpAppendUint32(mpAppendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func mpAppendNil(b []byte) []byte {
+	return append(b, mpNil)
+}
+
+func mpAppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, mpTrue)
+	}
+	return append(b, mpFalse)
+}
+
+func mpAppendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return mpAppendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, mpInt8, byte(v))
+	case v >= math.MinInt16:
+		return mpAppendUint16(append(b, mpInt16), uint16(v))
+	case v >= math.MinInt32:
+		return mpAppendUint32(append(b, mpInt32), uint32(v))
+	}
+	return mpAppendUint64(append(b, mpInt64), uint64(v))
+}
+
+func mpAppendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, mpUint8, byte(v))
+	case v <= math.MaxUint16:
+		return mpAppendUint16(append(b, mpUint16), uint16(v))
+	case v <= math.MaxUint32:
+		return mpAppendUint32(append(b, mpUint32), uint32(v))
+	}
+	return mpAppendUint64(append(b, mpUint64), v)
+}
+
+func mpAppendFloat32(b []byte, v float32) []byte {
+	return mpAppendUint32(append(b, mpFloat32), math.Float32bits(v))
+}
+
+func mpAppendFloat64(b []byte, v float64) []byte {
+	return mpAppendUint64(append(b, mpFloat64), math.Float64bits(v))
+}
+
+func mpAppendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, mpStr8, byte(n))
+	case n <= math.MaxUint16:
+		b = mpAppendUint16(append(b, mpStr16), uint16(n))
+	default:
+		b = mpAppendUint32(append(b, mpStr32), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func mpAppendBytes(b []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, mpBin8, byte(n))
+	case n <= math.MaxUint16:
+		b = mpAppendUint16(append(b, mpBin16), uint16(n))
+	default:
+		b = mpAppendUint32(append(b, mpBin32), uint32(n))
+	}
+	return append(b, v...)
+}
+
+func mpAppendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return mpAppendUint16(append(b, mpArray16), uint16(n))
+	}
+	return mpAppendUint32(append(b, mpArray32), uint32(n))
+}
+
+func mpAppendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return mpAppendUint16(append(b, mpMap16), uint16(n))
+	}
+	return mpAppendUint32(append(b, mpMap32), uint32(n))
+}
+
+func mpAppendExt(b []byte, typ int8, data []byte) []byte {
+	n := len(data)
+	switch {
+	case n == 1:
+		b = append(b, mpFixExt1)
+	case n == 2:
+		b = append(b, mpFixExt1+1)
+	case n == 4:
+		b = append(b, mpFixExt4)
+	case n == 8:
+		b = append(b, mpFixExt8)
+	case n == 16:
+		b = append(b, mpFixExt16)
+	case n <= math.MaxUint8:
+		b = append(b, mpExt8, byte(n))
+	case n <= math.MaxUint16:
+		b = mpAppendUint16(append(b, mpExt16), uint16(n))
+	default:
+		b = mpAppendUint32(append(b, mpExt32), uint32(n))
+	}
+	return append(append(b, byte(typ)), data...)
+}
+
+// mpAppendTime uses timestamp 96 format, location is not preserved
+func mpAppendTime(b []byte, t time.Time) []byte {
+	var data [12]byte
+	binary.BigEndian.PutUint32(data[:4], uint32(t.Nanosecond()))
+	binary.BigEndian.PutUint64(data[4:], uint64(t.Unix()))
+	return mpAppendExt(b, mpExtTime, data[:])
+}
+
+// mpExt is a value of unknown extension type
+type mpExt struct {
+	Type int8
+	Data []byte
+}
+
+// mpJSON is a value encoded as json
+type mpJSON []byte
+
+type msgpackReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.b) {
+		return nil, errMsgpackShort
+	}
+	res := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return res, nil
+}
+
+func (r *msgpackReader) byte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *msgpackReader) uint(n int) (uint64, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (r *msgpackReader) done() bool {
+	return r.pos >= len(r.b)
+}
+
+// readHeader reads array or map header and returns number of elements (pairs for map)
+func (r *msgpackReader) readHeader(isMap bool) (int, error) {
+	c, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch {
+	case !isMap && c&0xf0 == 0x90, isMap && c&0xf0 == 0x80:
+		return int(c & 0x0f), nil
+	case !isMap && c == mpArray16, isMap && c == mpMap16:
+		n, err = r.uint(2)
+	case !isMap && c == mpArray32, isMap && c == mpMap32:
+		n, err = r.uint(4)
+	default:
+		if isMap {
+			return 0, fmt.Errorf("msgpack: expected map, got 0x%02x", c)
+		}
+		return 0, fmt.Errorf("msgpack: expected array, got 0x%02x", c)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// every element takes at least one byte, so n is checked before any allocation
+	min := n
+	if isMap {
+		min *= 2
+	}
+	if min > uint64(len(r.b)-r.pos) {
+		return 0, errMsgpackShort
+	}
+	return int(n), nil
+}
+
+// readValue reads any value: nil, bool, int64, uint64, float32, float64, string, []byte,
+// []interface{}, map[string]interface{}, time.Time, mpJSON or mpExt
+func (r *msgpackReader) readValue() (interface{}, error) {
+	c, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		b, err := r.next(int(c & 0x1f))
+		return string(b), err
+	case c&0xf0 == 0x90, c&0xf0 == 0x80:
+		r.pos--
+		return r.readContainer(c&0xf0 == 0x80)
+	}
+
+	switch c {
+	case mpNil:
+		return nil, nil
+	case mpFalse:
+		return false, nil
+	case mpTrue:
+		return true, nil
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		return r.uint(1 << (c - mpUint8))
+	case mpInt8:
+		v, err := r.uint(1)
+		return int64(int8(v)), err
+	case mpInt16:
+		v, err := r.uint(2)
+		return int64(int16(v)), err
+	case mpInt32:
+		v, err := r.uint(4)
+		return int64(int32(v)), err
+	case mpInt64:
+		v, err := r.uint(8)
+		return int64(v), err
+	case mpFloat32:
+		v, err := r.uint(4)
+		return math.Float32frombits(uint32(v)), err
+	case mpFloat64:
+		v, err := r.uint(8)
+		return math.Float64frombits(v), err
+	case mpStr8, mpStr16, mpStr32:
+		n, err := r.uint(1 << (c - mpStr8))
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.next(int(n))
+		return string(b), err
+	case mpBin8, mpBin16, mpBin32:
+		n, err := r.uint(1 << (c - mpBin8))
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case mpArray16, mpArray32, mpMap16, mpMap32:
+		r.pos--
+		return r.readContainer(c == mpMap16 || c == mpMap32)
+	case mpFixExt1, mpFixExt1 + 1, mpFixExt4, mpFixExt8, mpFixExt16:
+		return r.readExt(1 << (c - mpFixExt1))
+	case mpExt8, mpExt16, mpExt32:
+		n, err := r.uint(1 << (c - mpExt8))
+		if err != nil {
+			return nil, err
+		}
+		return r.readExt(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unknown format 0x%02x", c)
+}
+
+func (r *msgpackReader) readContainer(isMap bool) (interface{}, error) {
+	n, err := r.readHeader(isMap)
+	if err != nil {
+		return nil, err
+	}
+	if !isMap {
+		res := make([]interface{}, n)
+		for i := range res {
+			if res[i], err = r.readValue(); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+	res := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		res[fmt.Sprint(k)] = v
+	}
+	return res, nil
+}
+
+func (r *msgpackReader) readExt(n int) (interface{}, error) {
+	t, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	switch int8(t) {
+	case mpExtTime:
+		switch n {
+		case 4:
+			return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+		case 8:
+			v := binary.BigEndian.Uint64(data)
+			return time.Unix(int64(v&0x3ffffffff), int64(v>>34)), nil
+		case 12:
+			return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data[:4]))), nil
+		}
+		return nil, fmt.Errorf("msgpack: wrong timestamp length %d", n)
+	case mpExtJSON:
+		return mpJSON(append([]byte(nil), data...)), nil
+	}
+	return mpExt{Type: int8(t), Data: append([]byte(nil), data...)}, nil
+}
@@ -0,0 +1,182 @@
+package inmemdb
+
+import "reflect"
+
+// ModelSortable interface, NULL is less than any other value (NULLS FIRST)
+func (fnil NullType) ModelLess(ms ModelSortable) bool {
+	return !IsNull(ms)
+}
+func (fnil NullType) ModelEqual(ms ModelSortable) bool {
+	return IsNull(ms)
+}
+
+// IsNull returns true for absent values, Null and nil pointers
+func IsNull(v interface{}) bool {
+	switch v.(type) {
+	case nil, NullType, *NullType:
+		return true
+	case String, UUIDv4:
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// ToSortable converts field value to ModelSortable:
+// NULL values (see IsNull) become Null, non-nil pointers are dereferenced
+func ToSortable(v interface{}) (ModelSortable, bool) {
+	switch vv := v.(type) {
+	case String:
+		return vv, true
+	case UUIDv4:
+		return vv, true
+	}
+	if IsNull(v) {
+		return Null, true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		v = rv.Elem().Interface()
+	}
+	s, ok := v.(ModelSortable)
+	return s, ok
+}
+
+var modelSortableType = reflect.TypeOf((*ModelSortable)(nil)).Elem()
+
+// IsSortableType reports whether values of type t (or *t) can be converted by ToSortable
+func IsSortableType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Implements(modelSortableType)
+}
+
+// SortableLess compares values with NULLS FIRST ordering
+func SortableLess(a, b ModelSortable) bool {
+	an, bn := IsNull(a), IsNull(b)
+	if an || bn {
+		return an && !bn
+	}
+	return a.ModelLess(b)
+}
+
+// SortableEqual treats all NULLs as equal, this is used for index ordering only
+func SortableEqual(a, b ModelSortable) bool {
+	an, bn := IsNull(a), IsNull(b)
+	if an || bn {
+		return an && bn
+	}
+	return a.ModelEqual(b)
+}
+
+// TriBool is a result of SQL three-valued logic
+type TriBool int8
+
+const (
+	TriFalse TriBool = iota
+	TriTrue
+	TriUnknown
+)
+
+func TriBoolOf(b bool) TriBool {
+	if b {
+		return TriTrue
+	}
+	return TriFalse
+}
+
+func (t TriBool) Not() TriBool {
+	switch t {
+	case TriTrue:
+		return TriFalse
+	case TriFalse:
+		return TriTrue
+	}
+	return TriUnknown
+}
+
+func (t TriBool) And(o TriBool) TriBool {
+	switch {
+	case t == TriFalse || o == TriFalse:
+		return TriFalse
+	case t == TriUnknown || o == TriUnknown:
+		return TriUnknown
+	}
+	return TriTrue
+}
+
+func (t TriBool) Or(o TriBool) TriBool {
+	switch {
+	case t == TriTrue || o == TriTrue:
+		return TriTrue
+	case t == TriUnknown || o == TriUnknown:
+		return TriUnknown
+	}
+	return TriFalse
+}
+
+// IsTrue is a WHERE clause semantics: UNKNOWN rejects the row
+func (t TriBool) IsTrue() bool {
+	return t == TriTrue
+}
+
+func (t TriBool) String() string {
+	switch t {
+	case TriTrue:
+		return "TRUE"
+	case TriFalse:
+		return "FALSE"
+	}
+	return "UNKNOWN"
+}
+
+type CompareOp int
+
+const (
+	OpEq CompareOp = iota
+	OpNe
+	OpLt
+	OpLe
+	OpGt
+	OpGe
+)
+
+func (op CompareOp) String() string {
+	switch op {
+	case OpEq:
+		return "="
+	case OpNe:
+		return "<>"
+	case OpLt:
+		return "<"
+	case OpLe:
+		return "<="
+	case OpGt:
+		return ">"
+	case OpGe:
+		return ">="
+	}
+	return "?"
+}
+
+// Compare3 compares values with SQL semantics: any comparison with NULL is UNKNOWN
+func Compare3(a ModelSortable, op CompareOp, b ModelSortable) TriBool {
+	if IsNull(a) || IsNull(b) {
+		return TriUnknown
+	}
+	switch op {
+	case OpEq:
+		return TriBoolOf(a.ModelEqual(b))
+	case OpNe:
+		return TriBoolOf(!a.ModelEqual(b))
+	case OpLt:
+		return TriBoolOf(a.ModelLess(b))
+	case OpLe:
+		return TriBoolOf(a.ModelLess(b) || a.ModelEqual(b))
+	case OpGt:
+		return TriBoolOf(b.ModelLess(a))
+	case OpGe:
+		return TriBoolOf(b.ModelLess(a) || a.ModelEqual(b))
+	}
+	return TriUnknown
+}
@@ -0,0 +1,165 @@
+package inmemdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadius is the mean Earth radius in meters
+const EarthRadius = 6371008.8
+
+// Point is a geographic point in degrees
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Valid reports whether coordinates are in range
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("POINT(%s %s)",
+		strconv.FormatFloat(p.Lon, 'f', -1, 64),
+		strconv.FormatFloat(p.Lat, 'f', -1, 64))
+}
+
+// Value returns point as WKT text 'POINT(lon lat)'
+func (p Point) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+// Scan reads WKT 'POINT(lon lat)' or PostgreSQL point '(lon,lat)'
+func (p *Point) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	default:
+		return fmt.Errorf("Scan: unable to scan type %T into Point", src)
+	}
+	s = strings.TrimSpace(s)
+	sep := ","
+	if u := strings.ToUpper(s); strings.HasPrefix(u, "POINT") {
+		s = strings.TrimSpace(s[len("POINT"):])
+		sep = " "
+	}
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return fmt.Errorf("Scan: invalid point %q", src)
+	}
+	parts := strings.Fields(strings.ReplaceAll(s[1:len(s)-1], sep, " "))
+	if len(parts) != 2 {
+		return fmt.Errorf("Scan: invalid point %q", src)
+	}
+	lon, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return fmt.Errorf("Scan: %v", err)
+	}
+	lat, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return fmt.Errorf("Scan: %v", err)
+	}
+	*p = Point{Lat: lat, Lon: lon}
+	return nil
+}
+
+// store.Converter interface, slices and arrays of two numbers are (lat, lon)
+func (p *Point) ConvertFrom(v interface{}) error {
+	switch vv := v.(type) {
+	case nil:
+		return nil
+	case Point:
+		*p = vv
+		return nil
+	case *Point:
+		*p = *vv
+		return nil
+	case [2]float64:
+		*p = Point{Lat: vv[0], Lon: vv[1]}
+		return nil
+	case []float64:
+		if len(vv) != 2 {
+			return fmt.Errorf("can't convert %v to Point", v)
+		}
+		*p = Point{Lat: vv[0], Lon: vv[1]}
+		return nil
+	case map[string]interface{}:
+		lat, ok1 := vv["lat"].(float64)
+		lon, ok2 := vv["lon"].(float64)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("can't convert %v to Point", v)
+		}
+		*p = Point{Lat: lat, Lon: lon}
+		return nil
+	}
+	return p.Scan(v)
+}
+
+func haverSin(theta float64) float64 {
+	s := math.Sin(theta / 2)
+	return s * s
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Distance returns great-circle distance to q in meters
+func (p Point) Distance(q Point) float64 {
+	h := haverSin(radians(q.Lat-p.Lat)) +
+		math.Cos(radians(p.Lat))*math.Cos(radians(q.Lat))*haverSin(radians(q.Lon-p.Lon))
+	return 2 * EarthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// Rect is a bounding box in degrees, MinLon > MaxLon means the box crosses the antimeridian
+type Rect struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// Contains reports whether p is inside r including bounds
+func (r Rect) Contains(p Point) bool {
+	if p.Lat < r.MinLat || p.Lat > r.MaxLat {
+		return false
+	}
+	if r.MinLon > r.MaxLon {
+		return p.Lon >= r.MinLon || p.Lon <= r.MaxLon
+	}
+	return p.Lon >= r.MinLon && p.Lon <= r.MaxLon
+}
+
+// RadiusRect returns bounding box of circle with center c and radius in meters
+func RadiusRect(c Point, meters float64) Rect {
+	dLat := meters / EarthRadius * 180 / math.Pi
+	r := Rect{
+		MinLat: math.Max(c.Lat-dLat, -90),
+		MaxLat: math.Min(c.Lat+dLat, 90),
+		MinLon: -180,
+		MaxLon: 180,
+	}
+	if r.MinLat == -90 || r.MaxLat == 90 {
+		// circle contains the pole
+		return r
+	}
+	sinRatio := math.Sin(meters/EarthRadius) / math.Cos(radians(c.Lat))
+	if sinRatio >= 1 {
+		return r
+	}
+	dLon := math.Asin(sinRatio) * 180 / math.Pi
+	r.MinLon, r.MaxLon = c.Lon-dLon, c.Lon+dLon
+	if r.MinLon < -180 {
+		r.MinLon += 360
+	}
+	if r.MaxLon > 180 {
+		r.MaxLon -= 360
+	}
+	return r
+}
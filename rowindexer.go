@@ -0,0 +1,66 @@
+package inmemdb
+
+// RowIndexer is a secondary index that is maintained by ModelTable on Upsert and Delete
+type RowIndexer interface {
+	// IndexRow adds row to index, it must not change the index on error
+	IndexRow(id ModelSortable, mo ModelObject) error
+	// UnindexRow removes row that was added by IndexRow
+	UnindexRow(id ModelSortable, mo ModelObject)
+}
+
+// AttachIndex adds all rows to ri and maintains it on further changes of table.
+// On error ri is left empty and is not attached.
+func (mt *ModelTable) AttachIndex(ri RowIndexer) error {
+	for i := 0; i < mt.rows.len(); i++ {
+		if err := ri.IndexRow(mt.rows.id(i), mt.rows.row(i)); err != nil {
+			for j := 0; j < i; j++ {
+				ri.UnindexRow(mt.rows.id(j), mt.rows.row(j))
+			}
+			return err
+		}
+	}
+	mt.indexers = append(mt.indexers, ri)
+	return nil
+}
+
+// DetachIndex stops maintaining of ri, it keeps the rows indexed at the moment
+func (mt *ModelTable) DetachIndex(ri RowIndexer) {
+	for i, x := range mt.indexers {
+		if x == ri {
+			mt.indexers = append(mt.indexers[:i], mt.indexers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Indexers returns attached indexes
+func (mt *ModelTable) Indexers() []RowIndexer {
+	return append([]RowIndexer(nil), mt.indexers...)
+}
+
+// indexRow replaces old row (if not nil) by mo in attached indexes, all indexes are unchanged on error
+func (mt *ModelTable) indexRow(id ModelSortable, old *ModelObject, mo ModelObject) error {
+	for i, ri := range mt.indexers {
+		if old != nil {
+			ri.UnindexRow(id, *old)
+		}
+		if err := ri.IndexRow(id, mo); err != nil {
+			if old != nil {
+				ri.IndexRow(id, *old)
+			}
+			mt.unindexRow(mt.indexers[:i], id, old, mo)
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexRow reverts indexRow for indexes ris
+func (mt *ModelTable) unindexRow(ris []RowIndexer, id ModelSortable, old *ModelObject, mo ModelObject) {
+	for _, ri := range ris {
+		ri.UnindexRow(id, mo)
+		if old != nil {
+			ri.IndexRow(id, *old)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package inmemdb
+
+// rowStore keeps rows of ModelTable sorted by IdField ascending, i is a position in this order.
+// Each row has a row number, that is not changed while the row exists and is reused after delete.
+type rowStore interface {
+	len() int
+	id(i int) ModelSortable
+	// search returns position of first row with id that is not less than id
+	search(id ModelSortable) int
+	field(i int, fd *FieldDescription) interface{}
+	row(i int) ModelObject
+	// insert and replace don't change the store on error, replace keeps row number
+	insert(i int, mo ModelObject) error
+	replace(i int, mo ModelObject) error
+	remove(i int)
+
+	num(i int) uint32
+	numID(num uint32) (ModelSortable, bool)
+	maxNum() uint32 // all row numbers are less than maxNum
+}
+
+// objectStore keeps model objects as is
+type objectStore struct {
+	idIdx int
+	t     btree[objectRow]
+	byNum []ModelSortable // nil for free numbers
+	free  []uint32
+}
+
+type objectRow struct {
+	mo  ModelObject
+	num uint32
+}
+
+func newObjectStore(md *ModelDescription) *objectStore {
+	return &objectStore{
+		idIdx: md.IdField.Idx,
+	}
+}
+
+func (s *objectStore) len() int               { return s.t.Len() }
+func (s *objectStore) id(i int) ModelSortable { return s.t.At(i).mo.v[s.idIdx].(ModelSortable) }
+func (s *objectStore) row(i int) ModelObject  { return s.t.At(i).mo }
+func (s *objectStore) num(i int) uint32       { return s.t.At(i).num }
+func (s *objectStore) maxNum() uint32         { return uint32(len(s.byNum)) }
+
+func (s *objectStore) search(id ModelSortable) int {
+	return s.t.Search(func(r objectRow) bool {
+		return !r.mo.v[s.idIdx].(ModelSortable).ModelLess(id)
+	})
+}
+
+func (s *objectStore) field(i int, fd *FieldDescription) interface{} {
+	return s.t.At(i).mo.v[fd.Idx]
+}
+
+func (s *objectStore) insert(i int, mo ModelObject) error {
+	var num uint32
+	if ln := len(s.free); ln > 0 {
+		num = s.free[ln-1]
+		s.free = s.free[:ln-1]
+	} else {
+		num = uint32(len(s.byNum))
+		s.byNum = append(s.byNum, nil)
+	}
+	s.byNum[num] = mo.v[s.idIdx].(ModelSortable)
+	s.t.Insert(i, objectRow{mo: mo, num: num})
+	return nil
+}
+
+func (s *objectStore) replace(i int, mo ModelObject) error {
+	r := s.t.At(i)
+	r.mo = mo
+	s.t.Set(i, r)
+	return nil
+}
+
+func (s *objectStore) remove(i int) {
+	r := s.t.Delete(i)
+	s.byNum[r.num] = nil
+	s.free = append(s.free, r.num)
+}
+
+func (s *objectStore) numID(num uint32) (ModelSortable, bool) {
+	if num >= uint32(len(s.byNum)) || s.byNum[num] == nil {
+		return nil, false
+	}
+	return s.byNum[num], true
+}
@@ -0,0 +1,294 @@
+package inmemdb
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+const rtreeMaxItems = 16
+
+// rtree is an R-tree of points with row numbers, nodes are split by the longest side of bounding box
+type rtree struct {
+	root *rtreeNode
+	n    int
+}
+
+type rtreeItem struct {
+	p   Point
+	num uint32
+}
+
+type rtreeNode struct {
+	box      Rect // MinLon <= MaxLon always
+	items    []rtreeItem
+	children []*rtreeNode // nil for leaf
+}
+
+func (n *rtreeNode) leaf() bool { return n.children == nil }
+
+func pointRect(p Point) Rect {
+	return Rect{MinLat: p.Lat, MinLon: p.Lon, MaxLat: p.Lat, MaxLon: p.Lon}
+}
+
+func unionRect(a, b Rect) Rect {
+	return Rect{
+		MinLat: math.Min(a.MinLat, b.MinLat),
+		MinLon: math.Min(a.MinLon, b.MinLon),
+		MaxLat: math.Max(a.MaxLat, b.MaxLat),
+		MaxLon: math.Max(a.MaxLon, b.MaxLon),
+	}
+}
+
+func rectArea(r Rect) float64 {
+	return (r.MaxLat - r.MinLat) * (r.MaxLon - r.MinLon)
+}
+
+// intersects doesn't support boxes that cross the antimeridian
+func (r Rect) intersects(o Rect) bool {
+	return r.MinLat <= o.MaxLat && o.MinLat <= r.MaxLat && r.MinLon <= o.MaxLon && o.MinLon <= r.MaxLon
+}
+
+func (n *rtreeNode) updateBox() {
+	first := true
+	if n.leaf() {
+		for _, it := range n.items {
+			if first {
+				n.box, first = pointRect(it.p), false
+			} else {
+				n.box = unionRect(n.box, pointRect(it.p))
+			}
+		}
+		return
+	}
+	for _, c := range n.children {
+		if first {
+			n.box, first = c.box, false
+		} else {
+			n.box = unionRect(n.box, c.box)
+		}
+	}
+}
+
+func (t *rtree) Len() int { return t.n }
+
+func (t *rtree) Insert(p Point, num uint32) {
+	if t.root == nil {
+		t.root = &rtreeNode{box: pointRect(p)}
+	}
+	if sibling := t.insert(t.root, rtreeItem{p: p, num: num}); sibling != nil {
+		root := &rtreeNode{children: []*rtreeNode{t.root, sibling}}
+		root.updateBox()
+		t.root = root
+	}
+	t.n++
+}
+
+// insert returns new sibling of n if n was split
+func (t *rtree) insert(n *rtreeNode, it rtreeItem) *rtreeNode {
+	if n.leaf() {
+		n.items = append(n.items, it)
+		if len(n.items) > rtreeMaxItems {
+			return splitLeaf(n)
+		}
+		n.box = unionRect(n.box, pointRect(it.p))
+		return nil
+	}
+	pr := pointRect(it.p)
+	best, bestEnl, bestArea := 0, math.Inf(1), math.Inf(1)
+	for i, c := range n.children {
+		area := rectArea(c.box)
+		enl := rectArea(unionRect(c.box, pr)) - area
+		if enl < bestEnl || (enl == bestEnl && area < bestArea) {
+			best, bestEnl, bestArea = i, enl, area
+		}
+	}
+	if sibling := t.insert(n.children[best], it); sibling != nil {
+		n.children = append(n.children, sibling)
+		if len(n.children) > rtreeMaxItems {
+			return splitInner(n)
+		}
+	}
+	n.updateBox()
+	return nil
+}
+
+// byLat reports whether node with box should be split by latitude
+func byLat(box Rect) bool {
+	return box.MaxLat-box.MinLat >= box.MaxLon-box.MinLon
+}
+
+func splitLeaf(n *rtreeNode) *rtreeNode {
+	n.updateBox()
+	lat := byLat(n.box)
+	sort.Slice(n.items, func(i, j int) bool {
+		if lat {
+			return n.items[i].p.Lat < n.items[j].p.Lat
+		}
+		return n.items[i].p.Lon < n.items[j].p.Lon
+	})
+	half := len(n.items) / 2
+	sibling := &rtreeNode{items: append([]rtreeItem(nil), n.items[half:]...)}
+	n.items = n.items[:half:half]
+	n.updateBox()
+	sibling.updateBox()
+	return sibling
+}
+
+func splitInner(n *rtreeNode) *rtreeNode {
+	n.updateBox()
+	lat := byLat(n.box)
+	sort.Slice(n.children, func(i, j int) bool {
+		a, b := n.children[i].box, n.children[j].box
+		if lat {
+			return a.MinLat+a.MaxLat < b.MinLat+b.MaxLat
+		}
+		return a.MinLon+a.MaxLon < b.MinLon+b.MaxLon
+	})
+	half := len(n.children) / 2
+	sibling := &rtreeNode{children: append([]*rtreeNode(nil), n.children[half:]...)}
+	n.children = n.children[:half:half]
+	n.updateBox()
+	sibling.updateBox()
+	return sibling
+}
+
+// Delete removes item, empty nodes are removed from the tree
+func (t *rtree) Delete(p Point, num uint32) bool {
+	if t.root == nil || !t.delete(t.root, rtreeItem{p: p, num: num}) {
+		return false
+	}
+	t.n--
+	for !t.root.leaf() && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+	}
+	if t.n == 0 {
+		t.root = nil
+	}
+	return true
+}
+
+func (t *rtree) delete(n *rtreeNode, it rtreeItem) bool {
+	if !n.box.intersects(pointRect(it.p)) {
+		return false
+	}
+	if n.leaf() {
+		for i, x := range n.items {
+			if x == it {
+				n.items = append(n.items[:i], n.items[i+1:]...)
+				n.updateBox()
+				return true
+			}
+		}
+		return false
+	}
+	for i, c := range n.children {
+		if !t.delete(c, it) {
+			continue
+		}
+		if (c.leaf() && len(c.items) == 0) || (!c.leaf() && len(c.children) == 0) {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+		}
+		n.updateBox()
+		return true
+	}
+	return false
+}
+
+// Search calls f for items in box r, that must not cross the antimeridian, until f returns false
+func (t *rtree) Search(r Rect, f func(p Point, num uint32) bool) {
+	if t.root != nil {
+		t.search(t.root, r, f)
+	}
+}
+
+func (t *rtree) search(n *rtreeNode, r Rect, f func(p Point, num uint32) bool) bool {
+	if n.leaf() {
+		for _, it := range n.items {
+			if r.Contains(it.p) && !f(it.p, it.num) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, c := range n.children {
+		if c.box.intersects(r) && !t.search(c, r, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// boxHaverSin returns lower bound of haversine of angular distance from p to box,
+// the box is on the sphere, so the closest point may be inside of its meridian side
+func boxHaverSin(p Point, cosLat float64, box Rect) float64 {
+	if p.Lon >= box.MinLon && p.Lon <= box.MaxLon {
+		switch {
+		case p.Lat < box.MinLat:
+			return haverSin(radians(p.Lat - box.MinLat))
+		case p.Lat > box.MaxLat:
+			return haverSin(radians(p.Lat - box.MaxLat))
+		}
+		return 0
+	}
+	hsDLon := math.Min(haverSin(radians(p.Lon-box.MinLon)), haverSin(radians(p.Lon-box.MaxLon)))
+	partial := func(lat float64) float64 {
+		return cosLat*math.Cos(radians(lat))*hsDLon + haverSin(radians(p.Lat-lat))
+	}
+	// latitude of the point of meridian closest to p
+	var extLat float64
+	if cosDLon := 1 - 2*hsDLon; cosDLon <= 0 {
+		extLat = math.Copysign(90, p.Lat)
+	} else {
+		extLat = math.Atan(math.Tan(radians(p.Lat))/cosDLon) * 180 / math.Pi
+	}
+	if extLat > box.MinLat && extLat < box.MaxLat {
+		return partial(extLat)
+	}
+	return math.Min(partial(box.MinLat), partial(box.MaxLat))
+}
+
+func itemHaverSin(p Point, cosLat float64, q Point) float64 {
+	return haverSin(radians(p.Lat-q.Lat)) + cosLat*math.Cos(radians(q.Lat))*haverSin(radians(p.Lon-q.Lon))
+}
+
+type rtreeQueueElem struct {
+	h    float64
+	node *rtreeNode
+	item rtreeItem
+}
+
+type rtreeQueue []rtreeQueueElem
+
+func (q rtreeQueue) Len() int              { return len(q) }
+func (q rtreeQueue) Less(i, j int) bool    { return q[i].h < q[j].h }
+func (q rtreeQueue) Swap(i, j int)         { q[i], q[j] = q[j], q[i] }
+func (q *rtreeQueue) Push(x interface{})   { *q = append(*q, x.(rtreeQueueElem)) }
+func (q *rtreeQueue) Pop() (x interface{}) { x, *q = (*q)[len(*q)-1], (*q)[:len(*q)-1]; return x }
+
+// Nearest calls f for items in order of distance from p until f returns false
+func (t *rtree) Nearest(p Point, f func(it rtreeItem, meters float64) bool) {
+	if t.root == nil {
+		return
+	}
+	cosLat := math.Cos(radians(p.Lat))
+	q := &rtreeQueue{{h: boxHaverSin(p, cosLat, t.root.box), node: t.root}}
+	for q.Len() > 0 {
+		e := heap.Pop(q).(rtreeQueueElem)
+		if e.node == nil {
+			if !f(e.item, 2*EarthRadius*math.Asin(math.Sqrt(math.Min(e.h, 1)))) {
+				return
+			}
+			continue
+		}
+		if e.node.leaf() {
+			for _, it := range e.node.items {
+				heap.Push(q, rtreeQueueElem{h: itemHaverSin(p, cosLat, it.p), item: it})
+			}
+			continue
+		}
+		for _, c := range e.node.children {
+			heap.Push(q, rtreeQueueElem{h: boxHaverSin(p, cosLat, c.box), node: c})
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package inmemdb
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a word of text with its position
+type Token struct {
+	Term string
+	Pos  int
+}
+
+// Tokenizer splits text into tokens, it must return the same tokens for the same text
+type Tokenizer interface {
+	Tokenize(text string) []Token
+}
+
+// Stemmer reduces word to its stem
+type Stemmer interface {
+	Stem(word string) string
+}
+
+// StemmerFunc is a function that implements Stemmer
+type StemmerFunc func(word string) string
+
+func (f StemmerFunc) Stem(word string) string { return f(word) }
+
+// SimpleTokenizer splits text by runes that are not letters or digits and converts tokens to lower case,
+// it works with any script including Cyrillic. Tokens shorter than MinLength runes are skipped.
+type SimpleTokenizer struct {
+	MinLength int
+}
+
+func (t SimpleTokenizer) Tokenize(text string) []Token {
+	var res []Token
+	start := -1
+	add := func(end int) {
+		w := text[start:end]
+		if utf8.RuneCountInString(w) >= t.MinLength {
+			res = append(res, Token{Term: strings.ToLower(w), Pos: len(res)})
+		}
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			add(i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		add(len(text))
+	}
+	return res
+}
+
+// stripSuffix removes the first matched suffix, if the rest has at least minStem runes
+func stripSuffix(word string, minStem int, suffixes []string) string {
+	for _, s := range suffixes {
+		if strings.HasSuffix(word, s) && utf8.RuneCountInString(word)-utf8.RuneCountInString(s) >= minStem {
+			return word[:len(word)-len(s)]
+		}
+	}
+	return word
+}
+
+var englishSuffixes = []string{
+	"ational", "fulness", "iveness", "ization", "ousness",
+	"ations", "ements", "ingly", "ments", "ation", "ement", "ities",
+	"ness", "ment", "able", "ible", "ings", "less", "ful", "ing", "ies", "ied",
+	"ity", "ive", "ize", "ise", "ed", "es", "ly", "er", "s",
+}
+
+// EnglishStemmer is a light suffix stripping stemmer for English words in lower case
+var EnglishStemmer = StemmerFunc(func(word string) string {
+	if strings.HasSuffix(word, "ss") {
+		return word
+	}
+	return stripSuffix(word, 3, englishSuffixes)
+})
+
+var russianSuffixes = []string{
+	"иями", "ями", "ами", "ией", "иям", "ием", "иях",
+	"ого", "его", "ому", "ему", "ыми", "ими", "ешь", "ишь", "ете", "ите", "ать", "ять", "ить", "еть",
+	"ая", "яя", "ое", "ее", "ые", "ие", "ый", "ий", "ой", "ей", "ую", "юю", "ом", "ем", "ам", "ям",
+	"ах", "ях", "ов", "ев", "ию", "ия", "ть",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// RussianStemmer is a light ending stripping stemmer for Russian words in lower case
+var RussianStemmer = StemmerFunc(func(word string) string {
+	word = strings.ReplaceAll(word, "ё", "е")
+	return stripSuffix(word, 3, russianSuffixes)
+})
+
+// LanguageStemmer uses RussianStemmer for words with Cyrillic letters and EnglishStemmer for others
+var LanguageStemmer = StemmerFunc(func(word string) string {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return RussianStemmer(word)
+		}
+	}
+	return EnglishStemmer(word)
+})
@@ -1,6 +1,15 @@
 package inmemdb
 
-import "strings"
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
 
 type String string
 
@@ -13,3 +22,103 @@ func (u String) ModelEqual(ms ModelSortable) bool {
 	mv := string(ms.(String))
 	return strings.Compare(string(u), mv) == 0
 }
+
+// FoldString is a case-insensitive string, it is ordered by runes with simple Unicode case folding.
+// Like on FoldString fields is case-insensitive too.
+type FoldString string
+
+// ModelSortable interface
+func (u FoldString) ModelLess(ms ModelSortable) bool {
+	return compareFold(string(u), string(ms.(FoldString))) < 0
+}
+func (u FoldString) ModelEqual(ms ModelSortable) bool {
+	return compareFold(string(u), string(ms.(FoldString))) == 0
+}
+
+func foldRune(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+func compareFold(a, b string) int {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if ra != rb {
+			if fa, fb := foldRune(ra), foldRune(rb); fa != fb {
+				if fa < fb {
+					return -1
+				}
+				return 1
+			}
+		}
+		a, b = a[na:], b[nb:]
+	}
+	switch {
+	case a != "":
+		return 1
+	case b != "":
+		return -1
+	}
+	return 0
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	for prefix != "" {
+		if s == "" {
+			return false
+		}
+		rs, ns := utf8.DecodeRuneInString(s)
+		rp, np := utf8.DecodeRuneInString(prefix)
+		if rs != rp && foldRune(rs) != foldRune(rp) {
+			return false
+		}
+		s, prefix = s[ns:], prefix[np:]
+	}
+	return true
+}
+
+// Collation selects Unicode collation for CollatedString, Collator must return a new collator on each call
+type Collation interface {
+	Collator() *collate.Collator
+}
+
+// RootCollation is the default Unicode collation (CLDR root)
+type RootCollation struct{}
+
+func (RootCollation) Collator() *collate.Collator {
+	return collate.New(language.Und)
+}
+
+// collators keeps pool of collators for each Collation type, collate.Collator is not safe for concurrent use
+var collators sync.Map
+
+func collatorPool(c Collation) *sync.Pool {
+	typ := reflect.TypeOf(c)
+	if p, ok := collators.Load(typ); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := collators.LoadOrStore(typ, &sync.Pool{
+		New: func() interface{} { return c.Collator() },
+	})
+	return p.(*sync.Pool)
+}
+
+// CollatedString is a string ordered by Unicode collation C, for example:
+//
+//	type ruCollation struct{}
+//	func (ruCollation) Collator() *collate.Collator { return collate.New(language.Russian, collate.IgnoreCase) }
+//	type Name = CollatedString[ruCollation]
+type CollatedString[C Collation] string
+
+func (u CollatedString[C]) compare(ms ModelSortable) int {
+	var c C
+	p := collatorPool(c)
+	cl := p.Get().(*collate.Collator)
+	res := cl.CompareString(string(u), string(ms.(CollatedString[C])))
+	p.Put(cl)
+	return res
+}
+
+// ModelSortable interface
+func (u CollatedString[C]) ModelLess(ms ModelSortable) bool  { return u.compare(ms) < 0 }
+func (u CollatedString[C]) ModelEqual(ms ModelSortable) bool { return u.compare(ms) == 0 }
@@ -0,0 +1,152 @@
+package inmemdb
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+var (
+	stringType     = reflect.TypeOf(String(""))
+	foldStringType = reflect.TypeOf(FoldString(""))
+)
+
+// stringFieldType returns type of field with string kind without pointer
+func stringFieldType(md *ModelDescription, fd *FieldDescription) (reflect.Type, error) {
+	typ := fd.StructField.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if !fd.IsStored() || typ.Kind() != reflect.String {
+		return nil, ErrorField{Type: md.ModelType, Field: fd.Name, Err: ErrNotString}
+	}
+	return typ, nil
+}
+
+// StartsWith returns iterator over ids of rows with field value that starts with prefix, NULLs don't match.
+// It is case-insensitive for FoldString fields.
+func (mt *ModelTable) StartsWith(fd *FieldDescription, prefix string) (IDIterator, error) {
+	typ, err := stringFieldType(mt.md, fd)
+	if err != nil {
+		return nil, err
+	}
+	hasPrefix := strings.HasPrefix
+	if typ == foldStringType {
+		hasPrefix = hasPrefixFold
+	}
+	return mt.matchString(fd, typ, prefix, func(s string) bool {
+		return hasPrefix(s, prefix)
+	}), nil
+}
+
+// Like returns iterator over ids of rows with field value that matches SQL LIKE pattern:
+// % matches any sequence of characters, _ matches one character, \ escapes the next character.
+// It is case-insensitive (ILIKE) for FoldString fields.
+func (mt *ModelTable) Like(fd *FieldDescription, pattern string) (IDIterator, error) {
+	typ, err := stringFieldType(mt.md, fd)
+	if err != nil {
+		return nil, err
+	}
+	re, prefix, err := likeRegexp(pattern, typ == foldStringType)
+	if err != nil {
+		return nil, err
+	}
+	return mt.matchString(fd, typ, prefix, re.MatchString), nil
+}
+
+// Regexp returns iterator over ids of rows with field value that matches re.
+// Literal prefix of regexp anchored by ^ is used for index narrowing.
+func (mt *ModelTable) Regexp(fd *FieldDescription, re *regexp.Regexp) (IDIterator, error) {
+	typ, err := stringFieldType(mt.md, fd)
+	if err != nil {
+		return nil, err
+	}
+	return mt.matchString(fd, typ, regexpPrefix(re), re.MatchString), nil
+}
+
+// matchString returns rows with not NULL values that start with prefix and matched by match.
+// Sorted index on String and FoldString fields keeps values with the same prefix together,
+// so only this range of index is read, other fields are scanned.
+func (mt *ModelTable) matchString(fd *FieldDescription, typ reflect.Type, prefix string, match func(string) bool) IDIterator {
+	mi := mt.idxs[fd.Idx]
+	if mi == nil || (typ != stringType && typ != foldStringType) {
+		return mt.scan(func(i int) bool {
+			v := mt.rows.field(i, fd)
+			return !IsNull(v) && match(reflect.Indirect(reflect.ValueOf(v)).String())
+		})
+	}
+	hasPrefix := strings.HasPrefix
+	if typ == foldStringType {
+		hasPrefix = hasPrefixFold
+	}
+	k := reflect.ValueOf(prefix).Convert(typ).Interface().(ModelSortable)
+	var ids SortableList
+	mi.kvs.Ascend(mi.searchK(k), func(kv KV) bool {
+		s := reflect.ValueOf(kv.K).String()
+		if !hasPrefix(s, prefix) {
+			return false
+		}
+		if match(s) {
+			ids = append(ids, kv.V)
+		}
+		return true
+	})
+	sort.Sort(ids)
+	return NewColumnIterator(ids, nil)
+}
+
+// likeRegexp converts LIKE pattern to regexp and returns its literal prefix
+func likeRegexp(pattern string, fold bool) (*regexp.Regexp, string, error) {
+	var b, prefix strings.Builder
+	b.WriteString("(?s)")
+	if fold {
+		b.WriteString("(?i)")
+	}
+	b.WriteByte('^')
+	literal, escaped := true, false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+			continue
+		case r == '%':
+			b.WriteString(".*")
+			literal = false
+			continue
+		case r == '_':
+			b.WriteByte('.')
+			literal = false
+			continue
+		}
+		b.WriteString(regexp.QuoteMeta(string(r)))
+		if literal {
+			prefix.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, "", fmt.Errorf("LIKE pattern %q must not end with escape character", pattern)
+	}
+	b.WriteByte('$')
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, "", err
+	}
+	return re, prefix.String(), nil
+}
+
+// regexpPrefix returns literal that must begin any matched string, it is empty for regexps not anchored by ^
+func regexpPrefix(re *regexp.Regexp) string {
+	sre, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil || sre.Op != syntax.OpConcat || len(sre.Sub) < 2 {
+		return ""
+	}
+	if sre.Sub[0].Op != syntax.OpBeginText || sre.Sub[1].Op != syntax.OpLiteral || sre.Sub[1].Flags&syntax.FoldCase != 0 {
+		return ""
+	}
+	return string(sre.Sub[1].Rune)
+}
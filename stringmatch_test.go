@@ -0,0 +1,128 @@
+package inmemdb
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+type testSvCollation struct{}
+
+func (testSvCollation) Collator() *collate.Collator { return collate.New(language.Swedish) }
+
+type TestStringMO struct {
+	ID   UUIDv4
+	Name String
+	Tag  *FoldString
+	Word CollatedString[testSvCollation]
+}
+
+func (t TestStringMO) StoreName() string { return "teststringmo" }
+
+func TestStringCompare(t *testing.T) {
+	if !FoldString("apple").ModelEqual(FoldString("APPLE")) || !FoldString("Äpfel").ModelEqual(FoldString("äPFEL")) {
+		t.Error("fold strings must be equal")
+	}
+	if !FoldString("apple").ModelLess(FoldString("Banana")) || FoldString("b").ModelLess(FoldString("A")) {
+		t.Error("wrong fold order")
+	}
+	words := []CollatedString[RootCollation]{"zebra", "Äpfel", "apple", "Zoo"}
+	sort.Slice(words, func(i, j int) bool { return words[i].ModelLess(words[j]) })
+	if words[0] != "Äpfel" || words[1] != "apple" || words[3] != "Zoo" {
+		t.Errorf("wrong collation order: %v", words)
+	}
+	// in Swedish ä is sorted after z
+	if sv := CollatedString[testSvCollation]("äpple"); !CollatedString[testSvCollation]("zebra").ModelLess(sv) {
+		t.Error("wrong swedish collation")
+	}
+}
+
+func TestStringMatch(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		t.Run(map[bool]string{false: "scan", true: "indexed"}[indexed], func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, tbl *Table[TestStringMO]) {
+				name := MustField[TestStringMO, String](tbl, "Name")
+				tag := MustField[TestStringMO, *FoldString](tbl, "Tag")
+				word := MustField[TestStringMO, CollatedString[testSvCollation]](tbl, "Word")
+				if indexed {
+					if err := name.CreateIndex(); err != nil {
+						t.Fatal(err)
+					}
+					if err := tag.CreateIndex(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				fold := func(s string) *FoldString { f := FoldString(s); return &f }
+				rows := []TestStringMO{
+					{NewV4(), "apple", fold("Fruit"), "äpple"},
+					{NewV4(), "application", fold("SOFT"), "zebra"},
+					{NewV4(), "apply 100%", nil, "apa"},
+					{NewV4(), "banana", fold("fruit"), "banan"},
+					{NewV4(), "Apple", fold("Software"), "Äpple"},
+				}
+				for _, row := range rows {
+					if err := tbl.Insert(row); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				expect := func(name string, it IDIterator, err error, idx ...int) {
+					t.Helper()
+					if err != nil {
+						t.Fatal(err)
+					}
+					got := collectIDs(it)
+					if len(got) != len(idx) {
+						t.Fatalf("%s: expected %d rows, got %d", name, len(idx), len(got))
+					}
+					for _, i := range idx {
+						found := false
+						for _, id := range got {
+							found = found || id.ModelEqual(rows[i].ID)
+						}
+						if !found {
+							t.Errorf("%s: row %d not found", name, i)
+						}
+					}
+					for i := 1; i < len(got); i++ {
+						if !SortableLess(got[i-1], got[i]) {
+							t.Errorf("%s: ids are not ascending", name)
+						}
+					}
+				}
+				it, err := name.StartsWith("appl")
+				expect("starts with appl", it, err, 0, 1, 2)
+				it, err = name.Like("app%")
+				expect("like app%", it, err, 0, 1, 2)
+				it, err = name.Like("%an_")
+				expect("like %an_", it, err, 3)
+				it, err = name.Like(`%\%`)
+				expect(`like %\%`, it, err, 2)
+				it, err = name.Like("apple")
+				expect("like apple", it, err, 0)
+				it, err = name.Regexp(regexp.MustCompile(`^app.*o`))
+				expect("regexp ^app.*o", it, err, 1)
+				it, err = name.Regexp(regexp.MustCompile(`(?i)^apple$`))
+				expect("regexp (?i)^apple$", it, err, 0, 4)
+				it, err = tag.StartsWith("FRU")
+				expect("fold starts with FRU", it, err, 0, 3)
+				it, err = tag.Like("soft%")
+				expect("fold like soft%", it, err, 1, 4)
+				it, err = word.Like("%pple")
+				expect("collated like", it, err, 0, 4)
+				if _, err := name.Like(`abc\`); err == nil {
+					t.Error("expected error for trailing escape")
+				}
+			})
+		})
+	}
+	if p := regexpPrefix(regexp.MustCompile(`^abc[0-9]+`)); p != "abc" {
+		t.Errorf("wrong regexp prefix %q", p)
+	}
+	if p := regexpPrefix(regexp.MustCompile(`abc`)); p != "" {
+		t.Errorf("unanchored regexp must not have prefix, got %q", p)
+	}
+}
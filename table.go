@@ -0,0 +1,302 @@
+package inmemdb
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// Table is a typed wrapper over ModelTable for model struct T
+type Table[T Storable] struct {
+	mt *ModelTable
+}
+
+func NewTable[T Storable](capacity int) (*Table[T], error) {
+	return newTable[T](capacity, NewModelTable)
+}
+
+// NewColumnarTable makes table with columnar storage, see NewColumnarModelTable
+func NewColumnarTable[T Storable](capacity int) (*Table[T], error) {
+	return newTable[T](capacity, NewColumnarModelTable)
+}
+
+func newTable[T Storable](capacity int, newModelTable func(md *ModelDescription, capacity int) *ModelTable) (*Table[T], error) {
+	var t T
+	typ := reflect.TypeOf(t)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("table type %v must be a struct", typ)
+	}
+	md, err := NewModelDescription(typ, t.StoreName())
+	if err != nil {
+		return nil, err
+	}
+	return &Table[T]{
+		mt: newModelTable(md, capacity),
+	}, nil
+}
+
+// WrapTable makes typed table over existing ModelTable, its model type must be T
+func WrapTable[T Storable](mt *ModelTable) (*Table[T], error) {
+	var t T
+	if mt.md.ModelType != reflect.TypeOf(t) {
+		return nil, fmt.Errorf("%w: table model type %s is not %T", ErrConstraint, mt.md.ModelType, t)
+	}
+	return &Table[T]{
+		mt: mt,
+	}, nil
+}
+
+func (t *Table[T]) ModelTable() *ModelTable {
+	return t.mt
+}
+
+func (t *Table[T]) MD() *ModelDescription {
+	return t.mt.md
+}
+
+func (t *Table[T]) Len() int {
+	return t.mt.Len()
+}
+
+// Object converts v to the new ModelObject of table
+func (t *Table[T]) Object(v T) (ModelObject, error) {
+	mo := NewModelObject(t.mt.md)
+	if err := mo.FromStruct(v); err != nil {
+		return ModelObject{}, err
+	}
+	return mo, nil
+}
+
+// Insert inserts v or replaces stored row with the same ID
+func (t *Table[T]) Insert(v T) error {
+	mo, err := t.Object(v)
+	if err != nil {
+		return err
+	}
+	return t.mt.Upsert(mo)
+}
+
+// Get returns row with id, ok is false if there is no such row, err is an error of conversion of row to T.
+// Unlike ModelTable.Get it returns error too, because stored values may be not convertible to T
+// and such row must not be reported as absent or returned as zero T silently.
+func (t *Table[T]) Get(id ModelSortable) (v T, ok bool, err error) {
+	mo, ok := t.mt.Get(id)
+	if !ok {
+		return v, false, nil
+	}
+	if err := mo.ToStruct(&v); err != nil {
+		return v, true, err
+	}
+	return v, true, nil
+}
+
+func (t *Table[T]) Delete(id ModelSortable) error {
+	return t.mt.Delete(id)
+}
+
+// All returns iterator over all rows in id order
+func (t *Table[T]) All() *Iterator[T] {
+	return t.Iter(NewColumnIterator(t.mt, nil))
+}
+
+// Iter returns typed iterator over rows with ids from it
+func (t *Table[T]) Iter(it IDIterator) *Iterator[T] {
+	return &Iterator[T]{
+		t:  t,
+		it: it,
+	}
+}
+
+// Iterator is a typed iterator over table rows, ids without rows are skipped
+type Iterator[T Storable] struct {
+	t   *Table[T]
+	it  IDIterator
+	id  ModelSortable
+	cur T
+	err error
+}
+
+func (i *Iterator[T]) Next() bool {
+	if i.err != nil {
+		return false
+	}
+	for i.it.HasNext() {
+		id := i.it.NextID()
+		mo, ok := i.t.mt.Get(id)
+		if !ok {
+			continue
+		}
+		var v T
+		if err := mo.ToStruct(&v); err != nil {
+			i.err = err
+			return false
+		}
+		i.id, i.cur = id, v
+		return true
+	}
+	i.err = iterErr(i.it)
+	return false
+}
+
+func (i *Iterator[T]) Value() T {
+	return i.cur
+}
+
+func (i *Iterator[T]) ID() ModelSortable {
+	return i.id
+}
+
+func (i *Iterator[T]) Err() error {
+	return i.err
+}
+
+// IDs returns underlying IDIterator
+func (i *Iterator[T]) IDs() IDIterator {
+	return i.it
+}
+
+// Collect reads all remaining rows
+func (i *Iterator[T]) Collect() ([]T, error) {
+	var res []T
+	for i.Next() {
+		res = append(res, i.cur)
+	}
+	return res, i.err
+}
+
+// Field is a typed handle of field with struct type V of table model T
+type Field[T Storable, V any] struct {
+	t  *Table[T]
+	fd *FieldDescription
+}
+
+func NewField[T Storable, V any](t *Table[T], fieldName string) (Field[T, V], error) {
+	fd, err := t.mt.md.GetColumnByFieldName(fieldName)
+	if err != nil {
+		return Field[T, V]{}, err
+	}
+	if vt := reflect.TypeOf((*V)(nil)).Elem(); vt != fd.StructField.Type {
+		return Field[T, V]{}, fmt.Errorf("%w: field %s has type %s, not %s", ErrConstraint, fieldName, fd.StructField.Type, vt)
+	}
+	return Field[T, V]{
+		t:  t,
+		fd: fd,
+	}, nil
+}
+
+// MustField is like NewField but panics on error
+func MustField[T Storable, V any](t *Table[T], fieldName string) Field[T, V] {
+	f, err := NewField[T, V](t, fieldName)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (f Field[T, V]) FD() *FieldDescription {
+	return f.fd
+}
+
+// Value returns field value of mo, ok is false for absent and NULL values (see IsNull),
+// including nil values of pointer type V
+func (f Field[T, V]) Value(mo ModelObject) (V, bool) {
+	v, ok := mo.Field(f.fd).(V)
+	if !ok || IsNull(v) {
+		var zero V
+		return zero, false
+	}
+	return v, true
+}
+
+func (f Field[T, V]) Set(mo ModelObject, v V) {
+	mo.Load()
+	mo.v[f.fd.Idx] = v
+}
+
+func (f Field[T, V]) CreateIndex() error {
+	_, err := f.t.mt.CreateIndex(f.fd)
+	return err
+}
+
+// BuildIndex starts online build of index, see ModelTable.BuildIndex
+func (f Field[T, V]) BuildIndex() (*IndexBuild, error) {
+	return f.t.mt.BuildIndex(f.fd)
+}
+
+// IndexStats returns statistics of index, see ModelTable.IndexStats
+func (f Field[T, V]) IndexStats() (IndexStats, bool) {
+	return f.t.mt.IndexStats(f.fd)
+}
+
+// CreatePartialIndex creates index of rows matched by pred, see ModelTable.CreatePartialIndex
+func (f Field[T, V]) CreatePartialIndex(name string, pred func(mo ModelObject) bool) error {
+	_, err := f.t.mt.CreatePartialIndex(name, f.fd, pred)
+	return err
+}
+
+// CreateHashIndex creates hash index, it is used by Eq and for OpEq in Where
+func (f Field[T, V]) CreateHashIndex() error {
+	_, err := f.t.mt.CreateHashIndex(f.fd)
+	return err
+}
+
+// CreateBitmapIndex creates bitmap index, see ModelTable.BitmapIndex
+func (f Field[T, V]) CreateBitmapIndex() error {
+	_, err := f.t.mt.CreateBitmapIndex(f.fd)
+	return err
+}
+
+// CreateTrigramIndex creates trigram index for substring and fuzzy search, see ModelTable.TrigramIndex
+func (f Field[T, V]) CreateTrigramIndex() error {
+	_, err := f.t.mt.CreateTrigramIndex(f.fd)
+	return err
+}
+
+// CreateGeoIndex creates spatial index for Point field, see ModelTable.GeoIndex
+func (f Field[T, V]) CreateGeoIndex() error {
+	_, err := f.t.mt.CreateGeoIndex(f.fd)
+	return err
+}
+
+// CreateVectorIndex creates nearest neighbour index for Vector field, see ModelTable.VectorIndex
+func (f Field[T, V]) CreateVectorIndex(opts VectorIndexOptions) error {
+	_, err := f.t.mt.CreateVectorIndex(f.fd, opts)
+	return err
+}
+
+func (f Field[T, V]) Where(op CompareOp, v V) (IDIterator, error) {
+	k, ok := ToSortable(v)
+	if !ok {
+		return nil, ErrorField{Type: f.t.mt.md.ModelType, Field: f.fd.Name, Value: v, Err: ErrNotSortable}
+	}
+	return f.t.mt.Where(f.fd, op, k)
+}
+
+func (f Field[T, V]) Eq(v V) (IDIterator, error) {
+	if hi := f.t.mt.HashIndex(f.fd); hi != nil && f.t.mt.idxs[f.fd.Idx] == nil {
+		return hi.IDs(v)
+	}
+	return f.Where(OpEq, v)
+}
+
+// StartsWith is prefix search on string field, see ModelTable.StartsWith
+func (f Field[T, V]) StartsWith(prefix string) (IDIterator, error) {
+	return f.t.mt.StartsWith(f.fd, prefix)
+}
+
+// Like is SQL LIKE on string field, see ModelTable.Like
+func (f Field[T, V]) Like(pattern string) (IDIterator, error) {
+	return f.t.mt.Like(f.fd, pattern)
+}
+
+func (f Field[T, V]) Regexp(re *regexp.Regexp) (IDIterator, error) {
+	return f.t.mt.Regexp(f.fd, re)
+}
+
+func (f Field[T, V]) IsNull() IDIterator {
+	return f.t.mt.IsNull(f.fd)
+}
+
+func (f Field[T, V]) IsNotNull() IDIterator {
+	return f.t.mt.IsNotNull(f.fd)
+}
@@ -0,0 +1,77 @@
+package inmemdb
+
+import "testing"
+
+func TestTypedTable(t *testing.T) {
+	forEachStore(t, func(t *testing.T, tbl *Table[TestMO]) {
+		name := MustField[TestMO, String](tbl, "Name")
+		if err := name.CreateIndex(); err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]UUIDv4, 3)
+		for i, n := range []String{"b", "a", "b"} {
+			ids[i] = NewV4()
+			if err := tbl.Insert(TestMO{ID: ids[i], Name: n}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		v, ok, err := tbl.Get(ids[1])
+		if err != nil || !ok || v.Name != "a" {
+			t.Fatalf("wrong row: %v", v)
+		}
+
+		it, err := name.Eq("b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := tbl.Iter(it).Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 || rows[0].Name != "b" || !rows[0].ID.ModelLess(rows[1].ID) {
+			t.Errorf("wrong rows: %v", rows)
+		}
+
+		if _, err := NewField[TestMO, string](tbl, "Name"); err == nil {
+			t.Error("field type must be checked")
+		}
+	})
+
+	tbl, err := NewTable[TestMO](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// object table keeps values as is, so conversion error can be seen by Get
+	bad := NewModelObject(tbl.MD())
+	bad.SetIDField(NewV4())
+	bad.v[MustField[TestMO, String](tbl, "Name").FD().Idx] = []int{1}
+	if err := tbl.ModelTable().Upsert(bad); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := tbl.Get(bad.IDField().(UUIDv4)); !ok || err == nil {
+		t.Errorf("conversion error must be returned, got %v, %v", ok, err)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	tbl, err := NewTable[TestNullMO](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := MustField[TestNullMO, *String](tbl, "Note")
+	mo := NewModelObject(tbl.MD())
+	if _, ok := note.Value(mo); ok {
+		t.Error("absent value must not be ok")
+	}
+	note.Set(mo, nil)
+	if v, ok := note.Value(mo); ok || v != nil {
+		t.Errorf("nil pointer is NULL, got %v, %v", v, ok)
+	}
+	s := String("a")
+	note.Set(mo, &s)
+	if v, ok := note.Value(mo); !ok || *v != "a" {
+		t.Errorf("wrong value %v, %v", v, ok)
+	}
+}
@@ -0,0 +1,381 @@
+package inmemdb
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TrigramIndex maps trigrams of string field values to Bitmap of row numbers, it is used
+// for substring (LIKE '%substr%') and fuzzy search. Trigrams are case-insensitive, so without
+// verification queries return candidates, that are a superset of matched rows.
+// With verification candidates are checked against field values, case-sensitive for String fields.
+type TrigramIndex struct {
+	mt   *ModelTable
+	fd   *FieldDescription
+	fold bool // FoldString field
+
+	m      map[string]*Bitmap
+	counts map[uint32]int // number of distinct padded trigrams of row value
+	rows   *Bitmap        // rows with not NULL values
+}
+
+// CreateTrigramIndex creates trigram index for string field fd and attaches it to the table
+func (mt *ModelTable) CreateTrigramIndex(fd *FieldDescription) (*TrigramIndex, error) {
+	typ, err := stringFieldType(mt.md, fd)
+	if err != nil {
+		return nil, err
+	}
+	ti := &TrigramIndex{
+		mt:     mt,
+		fd:     fd,
+		fold:   typ == foldStringType,
+		m:      make(map[string]*Bitmap),
+		counts: make(map[uint32]int),
+		rows:   &Bitmap{},
+	}
+	if err := mt.AttachIndex(ti); err != nil {
+		return nil, err
+	}
+	return ti, nil
+}
+
+// TrigramIndex returns attached trigram index for field fd or nil
+func (mt *ModelTable) TrigramIndex(fd *FieldDescription) *TrigramIndex {
+	for _, ri := range mt.indexers {
+		if ti, ok := ri.(*TrigramIndex); ok && ti.fd == fd {
+			return ti
+		}
+	}
+	return nil
+}
+
+func (ti *TrigramIndex) FD() *FieldDescription {
+	return ti.fd
+}
+
+// trigrams returns distinct trigrams of s with folded case, padded s has two spaces before and one after,
+// so short strings have trigrams too and their bounds are taken into account by similarity
+func trigrams(s string, padded bool) []string {
+	rs := make([]rune, 0, len(s)+3)
+	if padded {
+		rs = append(rs, ' ', ' ')
+	}
+	for _, r := range s {
+		rs = append(rs, foldRune(r))
+	}
+	if padded {
+		rs = append(rs, ' ')
+	}
+	var res []string
+	seen := make(map[string]struct{})
+	for i := 0; i+3 <= len(rs); i++ {
+		g := string(rs[i : i+3])
+		if _, ok := seen[g]; !ok {
+			seen[g] = struct{}{}
+			res = append(res, g)
+		}
+	}
+	return res
+}
+
+// value returns field value of mo, ok is false for NULL
+func (ti *TrigramIndex) value(v interface{}) (string, bool) {
+	if IsNull(v) {
+		return "", false
+	}
+	return reflect.Indirect(reflect.ValueOf(v)).String(), true
+}
+
+// IndexRow is RowIndexer interface, row must be stored in the table
+func (ti *TrigramIndex) IndexRow(id ModelSortable, mo ModelObject) error {
+	num, ok := ti.mt.RowNumber(id)
+	if !ok {
+		return ErrorField{Type: ti.mt.md.ModelType, Field: ti.mt.md.IdField.Name, Value: id, Err: ErrNotFound}
+	}
+	s, ok := ti.value(mo.val(ti.fd.Idx))
+	if !ok {
+		return nil
+	}
+	grams := trigrams(s, true)
+	for _, g := range grams {
+		b := ti.m[g]
+		if b == nil {
+			b = &Bitmap{}
+			ti.m[g] = b
+		}
+		b.Add(num)
+	}
+	ti.counts[num] = len(grams)
+	ti.rows.Add(num)
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (ti *TrigramIndex) UnindexRow(id ModelSortable, mo ModelObject) {
+	num, ok := ti.mt.RowNumber(id)
+	if !ok {
+		return
+	}
+	s, ok := ti.value(mo.val(ti.fd.Idx))
+	if !ok {
+		return
+	}
+	for _, g := range trigrams(s, true) {
+		if b := ti.m[g]; b != nil {
+			if b.Remove(num); b.IsEmpty() {
+				delete(ti.m, g)
+			}
+		}
+	}
+	delete(ti.counts, num)
+	ti.rows.Remove(num)
+}
+
+// Len returns number of distinct trigrams
+func (ti *TrigramIndex) Len() int {
+	return len(ti.m)
+}
+
+// candidates returns rows that have all trigrams of literals, literals shorter than 3 runes don't narrow the result
+func (ti *TrigramIndex) candidates(literals []string) *Bitmap {
+	var res *Bitmap
+	for _, lit := range literals {
+		for _, g := range trigrams(lit, false) {
+			b := ti.m[g]
+			if b == nil {
+				return &Bitmap{}
+			}
+			if res == nil {
+				res = b
+			} else {
+				res = res.And(b)
+			}
+		}
+	}
+	if res == nil {
+		return ti.rows.Clone()
+	}
+	return res.Clone()
+}
+
+// verify returns rows of b with field values matched by match
+func (ti *TrigramIndex) verify(b *Bitmap, match func(string) bool) *Bitmap {
+	res := &Bitmap{}
+	b.Iterate(func(num uint32) bool {
+		id, ok := ti.mt.RowID(num)
+		if !ok {
+			return true
+		}
+		i, ok := ti.mt.search(id)
+		if !ok {
+			return true
+		}
+		if s, ok := ti.value(ti.mt.rows.field(i, ti.fd)); ok && match(s) {
+			res.Add(num)
+		}
+		return true
+	})
+	return res
+}
+
+// Contains returns iterator over ids of rows with field value that contains substr in ascending order
+func (ti *TrigramIndex) Contains(substr string, verify bool) IDIterator {
+	b := ti.candidates([]string{substr})
+	if verify {
+		contains := strings.Contains
+		if ti.fold {
+			contains = containsFold
+		}
+		b = ti.verify(b, func(s string) bool { return contains(s, substr) })
+	}
+	return ti.mt.BitmapIDs(b)
+}
+
+// Like returns iterator over ids of rows with field value that matches LIKE pattern, see ModelTable.Like.
+// Literal parts of pattern are used to find candidates.
+func (ti *TrigramIndex) Like(pattern string, verify bool) (IDIterator, error) {
+	re, _, err := likeRegexp(pattern, ti.fold)
+	if err != nil {
+		return nil, err
+	}
+	b := ti.candidates(likeLiterals(pattern))
+	if verify {
+		b = ti.verify(b, re.MatchString)
+	}
+	return ti.mt.BitmapIDs(b), nil
+}
+
+// SimilarHit is a row found by similarity query
+type SimilarHit struct {
+	ID    ModelSortable
+	Score float64
+}
+
+// shared returns number of padded trigrams of s shared with each row and number of trigrams of s
+func (ti *TrigramIndex) shared(s string) (map[uint32]int, int) {
+	grams := trigrams(s, true)
+	res := make(map[uint32]int)
+	for _, g := range grams {
+		if b := ti.m[g]; b != nil {
+			b.Iterate(func(num uint32) bool {
+				res[num]++
+				return true
+			})
+		}
+	}
+	return res, len(grams)
+}
+
+// Similar returns rows with Jaccard similarity of trigram sets of field value and s not less than threshold,
+// ordered by similarity descending
+func (ti *TrigramIndex) Similar(s string, threshold float64) []SimilarHit {
+	shared, n := ti.shared(s)
+	var res []SimilarHit
+	for num, c := range shared {
+		score := float64(c) / float64(n+ti.counts[num]-c)
+		if score < threshold {
+			continue
+		}
+		if id, ok := ti.mt.RowID(num); ok {
+			res = append(res, SimilarHit{ID: id, Score: score})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return SortableLess(res[i].ID, res[j].ID)
+	})
+	return res
+}
+
+// SimilarIDs is like Similar, but returns iterator over ids in ascending order
+func (ti *TrigramIndex) SimilarIDs(s string, threshold float64) IDIterator {
+	b := &Bitmap{}
+	for _, hit := range ti.Similar(s, threshold) {
+		if num, ok := ti.mt.RowNumber(hit.ID); ok {
+			b.Add(num)
+		}
+	}
+	return ti.mt.BitmapIDs(b)
+}
+
+// Fuzzy returns iterator over ids of rows with field value within Levenshtein distance maxDist from s.
+// Each edit changes at most 3 trigrams, so candidates are rows that share enough trigrams with s.
+// Verification compares case-insensitive for FoldString fields only.
+func (ti *TrigramIndex) Fuzzy(s string, maxDist int, verify bool) IDIterator {
+	shared, n := ti.shared(s)
+	var b *Bitmap
+	if need := n - 3*maxDist; need <= 0 {
+		b = ti.rows.Clone()
+	} else {
+		b = &Bitmap{}
+		for num, c := range shared {
+			if c >= need {
+				b.Add(num)
+			}
+		}
+	}
+	if verify {
+		b = ti.verify(b, func(v string) bool {
+			if ti.fold {
+				return levenshtein(v, s, foldRune) <= maxDist
+			}
+			return Levenshtein(v, s) <= maxDist
+		})
+	}
+	return ti.mt.BitmapIDs(b)
+}
+
+// TrigramSimilarity returns Jaccard similarity of case-insensitive trigram sets of a and b
+func TrigramSimilarity(a, b string) float64 {
+	ga, gb := trigrams(a, true), trigrams(b, true)
+	set := make(map[string]struct{}, len(ga))
+	for _, g := range ga {
+		set[g] = struct{}{}
+	}
+	shared := 0
+	for _, g := range gb {
+		if _, ok := set[g]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ga)+len(gb)-shared)
+}
+
+// Levenshtein returns edit distance between a and b in runes
+func Levenshtein(a, b string) int {
+	return levenshtein(a, b, func(r rune) rune { return r })
+}
+
+func levenshtein(a, b string, norm func(rune) rune) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if norm(ra[i-1]) == norm(rb[j-1]) {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, x := range vs {
+		if x < v {
+			v = x
+		}
+	}
+	return v
+}
+
+func containsFold(s, substr string) bool {
+	for {
+		if hasPrefixFold(s, substr) {
+			return true
+		}
+		if s == "" {
+			return false
+		}
+		_, n := utf8.DecodeRuneInString(s)
+		s = s[n:]
+	}
+}
+
+// likeLiterals returns literal parts of LIKE pattern between wildcards
+func likeLiterals(pattern string) []string {
+	var res []string
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+			continue
+		case r == '%' || r == '_':
+			if b.Len() > 0 {
+				res = append(res, b.String())
+				b.Reset()
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() > 0 {
+		res = append(res, b.String())
+	}
+	return res
+}
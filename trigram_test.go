@@ -0,0 +1,114 @@
+package inmemdb
+
+import "testing"
+
+type TestSkuMO struct {
+	ID   UUIDv4
+	SKU  String
+	Name *FoldString
+}
+
+func (t TestSkuMO) StoreName() string { return "testskumo" }
+
+func TestLevenshtein(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		d    int
+	}{
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"молоко", "малако", 2},
+		{"same", "same", 0},
+	} {
+		if d := Levenshtein(tc.a, tc.b); d != tc.d {
+			t.Errorf("%q %q: expected %d, got %d", tc.a, tc.b, tc.d, d)
+		}
+	}
+	if s := TrigramSimilarity("word", "WORD"); s != 1 {
+		t.Errorf("expected 1, got %v", s)
+	}
+	if s := TrigramSimilarity("word", "xyz"); s != 0 {
+		t.Errorf("expected 0, got %v", s)
+	}
+}
+
+func TestTrigramIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, tbl *Table[TestSkuMO]) {
+		mt := tbl.ModelTable()
+		sku := MustField[TestSkuMO, String](tbl, "SKU")
+		name := MustField[TestSkuMO, *FoldString](tbl, "Name")
+		if err := sku.CreateTrigramIndex(); err != nil {
+			t.Fatal(err)
+		}
+		names, err := mt.CreateTrigramIndex(name.FD())
+		if err != nil {
+			t.Fatal(err)
+		}
+		skus := mt.TrigramIndex(sku.FD())
+
+		fold := func(s string) *FoldString { f := FoldString(s); return &f }
+		rows := []TestSkuMO{
+			{NewV4(), "AB-1234-X", fold("Wireless Mouse")},
+			{NewV4(), "ab-9123-y", fold("Wired Keyboard")},
+			{NewV4(), "CD-5678-X", fold("Mouse Pad")},
+			{NewV4(), "EF-0001", nil},
+		}
+		for _, row := range rows {
+			if err := tbl.Insert(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expect := func(name string, it IDIterator, idx ...int) {
+			t.Helper()
+			got := collectIDs(it)
+			if len(got) != len(idx) {
+				t.Fatalf("%s: expected %d rows, got %d", name, len(idx), len(got))
+			}
+			for _, i := range idx {
+				found := false
+				for _, id := range got {
+					found = found || id.ModelEqual(rows[i].ID)
+				}
+				if !found {
+					t.Errorf("%s: row %d not found", name, i)
+				}
+			}
+		}
+		// candidates are case-insensitive, verification is case-sensitive for String
+		expect("candidates ab-", skus.Contains("ab-", false), 0, 1)
+		expect("verified ab-", skus.Contains("ab-", true), 1)
+		expect("verified 123", skus.Contains("123", true), 0, 1)
+		expect("short substring", skus.Contains("-X", true), 0, 2)
+		expect("fold contains", names.Contains("MOUSE", true), 0, 2)
+		like, err := skus.Like("%-5_78-%", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect("like", like, 2)
+
+		expect("fuzzy", names.Fuzzy("wireles mose", 2, true), 0)
+		expect("fuzzy candidates", names.Fuzzy("wireles mose", 2, false), 0)
+		expect("fuzzy far", names.Fuzzy("keyboard", 2, true))
+		hits := names.Similar("mouse", 0.5)
+		if len(hits) != 1 || !hits[0].ID.ModelEqual(rows[2].ID) {
+			t.Errorf("wrong similar rows: %v", hits)
+		}
+		hits = names.Similar("wire", 0.1)
+		if len(hits) != 2 || hits[0].Score < hits[1].Score {
+			t.Errorf("wrong similar rows: %v", hits)
+		}
+		expect("similar ids", names.SimilarIDs("wire", 0.1), 0, 1)
+
+		rows[2].SKU = "GH-1111"
+		if err := tbl.Insert(rows[2]); err != nil {
+			t.Fatal(err)
+		}
+		if err := tbl.Delete(rows[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		expect("after update", skus.Contains("8-x", false))
+		expect("after update", skus.Contains("111", true), 2)
+		expect("after delete", names.Contains("mouse", false), 2)
+	})
+}
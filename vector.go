@@ -0,0 +1,173 @@
+package inmemdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Vector is an embedding, its text form is '[1,2,3]' like in pgvector
+type Vector []float32
+
+func (v Vector) String() string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// Value returns vector as text, nil vector is NULL
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return v.String(), nil
+}
+
+// Scan reads vector from text '[1,2,3]'
+func (v *Vector) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	default:
+		return fmt.Errorf("Scan: unable to scan type %T into Vector", src)
+	}
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return fmt.Errorf("Scan: invalid vector %q", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	res := Vector{}
+	if s != "" {
+		for _, part := range strings.Split(s, ",") {
+			x, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+			if err != nil {
+				return fmt.Errorf("Scan: %v", err)
+			}
+			res = append(res, float32(x))
+		}
+	}
+	*v = res
+	return nil
+}
+
+// store.Converter interface
+func (v *Vector) ConvertFrom(src interface{}) error {
+	switch vv := src.(type) {
+	case nil:
+		return nil
+	case Vector:
+		*v = vv
+		return nil
+	case *Vector:
+		*v = *vv
+		return nil
+	case []float32:
+		*v = vv
+		return nil
+	case []float64:
+		res := make(Vector, len(vv))
+		for i, x := range vv {
+			res[i] = float32(x)
+		}
+		*v = res
+		return nil
+	case []interface{}:
+		// decoded JSON array
+		res := make(Vector, len(vv))
+		for i, x := range vv {
+			f, ok := x.(float64)
+			if !ok {
+				return fmt.Errorf("can't convert %v to Vector", src)
+			}
+			res[i] = float32(f)
+		}
+		*v = res
+		return nil
+	}
+	return v.Scan(src)
+}
+
+// VectorMetric is a distance function of vectors, less distance means more similar vectors
+type VectorMetric int
+
+const (
+	// MetricCosine is 1 - cosine similarity
+	MetricCosine VectorMetric = iota
+	// MetricL2 is Euclidean distance
+	MetricL2
+	// MetricDot is negative inner product
+	MetricDot
+)
+
+func (m VectorMetric) String() string {
+	switch m {
+	case MetricCosine:
+		return "cosine"
+	case MetricL2:
+		return "l2"
+	case MetricDot:
+		return "dot"
+	}
+	return "?"
+}
+
+// Distance returns distance between a and b, vectors must have the same length
+func (m VectorMetric) Distance(a, b Vector) float64 {
+	switch m {
+	case MetricCosine:
+		na, nb := dotProduct(a, a), dotProduct(b, b)
+		if na == 0 || nb == 0 {
+			return 1
+		}
+		return 1 - dotProduct(a, b)/math.Sqrt(na*nb)
+	case MetricL2:
+		return math.Sqrt(squaredL2(a, b))
+	}
+	return -dotProduct(a, b)
+}
+
+func dotProduct(a, b Vector) float64 {
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return float64(s)
+}
+
+func squaredL2(a, b Vector) float64 {
+	var s float32
+	for i := range a {
+		d := a[i] - b[i]
+		s += d * d
+	}
+	return float64(s)
+}
+
+// normalize returns copy of v with unit length, zero vector is copied as is
+func normalize(v Vector) Vector {
+	res := make(Vector, len(v))
+	n := math.Sqrt(dotProduct(v, v))
+	for i, x := range v {
+		if n > 0 {
+			res[i] = float32(float64(x) / n)
+		} else {
+			res[i] = x
+		}
+	}
+	return res
+}
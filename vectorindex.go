@@ -0,0 +1,466 @@
+package inmemdb
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+)
+
+var vectorType = reflect.TypeOf(Vector{})
+
+// VectorIndexOptions configures VectorIndex, zero values are replaced by defaults
+type VectorIndexOptions struct {
+	Metric VectorMetric
+	// Dim is the length of vectors, 0 means the length of the first indexed vector
+	Dim int
+	// M is the max number of links of node on upper layers of graph, 2*M on the bottom layer, default 16
+	M int
+	// EfConstruction is the size of candidates list on insert, default 200
+	EfConstruction int
+	// EfSearch is the size of candidates list on search, it is increased to k if needed, default 64
+	EfSearch int
+	// ExactThreshold is the number of rows (or rows allowed by filter), up to which search is brute-force, default 1000
+	ExactThreshold int
+}
+
+// VectorHit is a row found by vector search
+type VectorHit struct {
+	ID       ModelSortable
+	Distance float64
+}
+
+// VectorIndex is an approximate nearest neighbour index on Vector field (HNSW graph).
+// Deleted rows are kept in graph as tombstones for navigation, graph is rebuilt when tombstones
+// outnumber live rows. Empty vectors are not indexed.
+type VectorIndex struct {
+	mt   *ModelTable
+	fd   *FieldDescription
+	opts VectorIndexOptions
+	dim  int
+	rnd  *rand.Rand
+	mult float64 // level generation factor
+
+	nodes    []hnswNode
+	byNum    map[uint32]int32 // row number -> live node
+	entry    int32            // -1 for empty graph
+	maxLevel int
+	deleted  int
+	visited  []uint32 // visit generation by node
+	visitGen uint32
+}
+
+type hnswNode struct {
+	vec     Vector // normalized for MetricCosine
+	num     uint32
+	deleted bool
+	links   [][]int32 // by layer
+}
+
+// CreateVectorIndex creates vector index for Vector field fd and attaches it to the table
+func (mt *ModelTable) CreateVectorIndex(fd *FieldDescription, opts VectorIndexOptions) (*VectorIndex, error) {
+	typ := fd.StructField.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if !fd.IsStored() || typ != vectorType {
+		return nil, ErrorField{Type: mt.md.ModelType, Field: fd.Name, Err: ErrNotVector}
+	}
+	if opts.M <= 1 {
+		opts.M = 16
+	}
+	if opts.EfConstruction <= 0 {
+		opts.EfConstruction = 200
+	}
+	if opts.EfSearch <= 0 {
+		opts.EfSearch = 64
+	}
+	if opts.ExactThreshold <= 0 {
+		opts.ExactThreshold = 1000
+	}
+	vi := &VectorIndex{
+		mt:    mt,
+		fd:    fd,
+		opts:  opts,
+		dim:   opts.Dim,
+		rnd:   rand.New(rand.NewSource(1)),
+		mult:  1 / math.Log(float64(opts.M)),
+		byNum: make(map[uint32]int32),
+		entry: -1,
+	}
+	if err := mt.AttachIndex(vi); err != nil {
+		return nil, err
+	}
+	return vi, nil
+}
+
+// VectorIndex returns attached vector index for field fd or nil
+func (mt *ModelTable) VectorIndex(fd *FieldDescription) *VectorIndex {
+	for _, ri := range mt.indexers {
+		if vi, ok := ri.(*VectorIndex); ok && vi.fd == fd {
+			return vi
+		}
+	}
+	return nil
+}
+
+func (vi *VectorIndex) FD() *FieldDescription {
+	return vi.fd
+}
+
+// Len returns number of indexed vectors
+func (vi *VectorIndex) Len() int {
+	return len(vi.byNum)
+}
+
+// Dim returns the length of indexed vectors, 0 if it is not known yet
+func (vi *VectorIndex) Dim() int {
+	return vi.dim
+}
+
+func (vi *VectorIndex) vector(v interface{}) Vector {
+	switch vv := v.(type) {
+	case Vector:
+		return vv
+	case *Vector:
+		if vv != nil {
+			return *vv
+		}
+	}
+	return nil
+}
+
+// prepare returns copy of v for graph
+func (vi *VectorIndex) prepare(v Vector) Vector {
+	if vi.opts.Metric == MetricCosine {
+		return normalize(v)
+	}
+	return append(Vector(nil), v...)
+}
+
+// dist is a distance for graph, that is monotonic with metric
+func (vi *VectorIndex) dist(a, b Vector) float64 {
+	switch vi.opts.Metric {
+	case MetricCosine:
+		return 1 - dotProduct(a, b)
+	case MetricL2:
+		return squaredL2(a, b)
+	}
+	return -dotProduct(a, b)
+}
+
+func (vi *VectorIndex) metricDist(d float64) float64 {
+	if vi.opts.Metric == MetricL2 {
+		return math.Sqrt(d)
+	}
+	return d
+}
+
+// IndexRow is RowIndexer interface, row must be stored in the table
+func (vi *VectorIndex) IndexRow(id ModelSortable, mo ModelObject) error {
+	num, ok := vi.mt.RowNumber(id)
+	if !ok {
+		return ErrorField{Type: vi.mt.md.ModelType, Field: vi.mt.md.IdField.Name, Value: id, Err: ErrNotFound}
+	}
+	v := vi.vector(mo.val(vi.fd.Idx))
+	if len(v) == 0 {
+		return nil
+	}
+	if vi.dim == 0 {
+		vi.dim = len(v)
+	} else if len(v) != vi.dim {
+		return ErrorField{Type: vi.mt.md.ModelType, Field: vi.fd.Name, Value: fmt.Sprintf("dimension %d is not %d", len(v), vi.dim), Err: ErrConstraint}
+	}
+	vi.insert(num, vi.prepare(v))
+	return nil
+}
+
+// UnindexRow is RowIndexer interface
+func (vi *VectorIndex) UnindexRow(id ModelSortable, mo ModelObject) {
+	num, ok := vi.mt.RowNumber(id)
+	if !ok {
+		return
+	}
+	n, ok := vi.byNum[num]
+	if !ok {
+		return
+	}
+	delete(vi.byNum, num)
+	vi.nodes[n].deleted = true
+	vi.deleted++
+	if vi.deleted > len(vi.byNum) {
+		vi.rebuild()
+	}
+}
+
+// rebuild makes new graph of live nodes
+func (vi *VectorIndex) rebuild() {
+	nodes := vi.nodes
+	vi.nodes, vi.visited = nil, nil
+	vi.byNum = make(map[uint32]int32, len(vi.byNum))
+	vi.entry, vi.maxLevel, vi.deleted = -1, 0, 0
+	for _, n := range nodes {
+		if !n.deleted {
+			vi.insert(n.num, n.vec)
+		}
+	}
+}
+
+type hnswCand struct {
+	id int32
+	d  float64
+}
+
+// hnswHeap is a min-heap of candidates by distance, or max-heap if max is true
+type hnswHeap struct {
+	c   []hnswCand
+	max bool
+}
+
+func (h *hnswHeap) Len() int { return len(h.c) }
+func (h *hnswHeap) Less(i, j int) bool {
+	if h.max {
+		return h.c[i].d > h.c[j].d
+	}
+	return h.c[i].d < h.c[j].d
+}
+func (h *hnswHeap) Swap(i, j int)      { h.c[i], h.c[j] = h.c[j], h.c[i] }
+func (h *hnswHeap) Push(x interface{}) { h.c = append(h.c, x.(hnswCand)) }
+func (h *hnswHeap) Pop() interface{} {
+	x := h.c[len(h.c)-1]
+	h.c = h.c[:len(h.c)-1]
+	return x
+}
+
+// searchLayer returns up to ef nearest to q nodes accepted by accept (nil accepts all) in ascending order,
+// not accepted nodes are used for navigation only
+func (vi *VectorIndex) searchLayer(q Vector, eps []int32, ef, layer int, accept func(id int32) bool) []hnswCand {
+	if len(vi.visited) < len(vi.nodes) {
+		vi.visited = make([]uint32, len(vi.nodes)*2)
+		vi.visitGen = 0
+	}
+	if vi.visitGen++; vi.visitGen == 0 {
+		for i := range vi.visited {
+			vi.visited[i] = 0
+		}
+		vi.visitGen = 1
+	}
+	gen := vi.visitGen
+	cands := &hnswHeap{}
+	res := &hnswHeap{max: true}
+	for _, ep := range eps {
+		vi.visited[ep] = gen
+		c := hnswCand{id: ep, d: vi.dist(q, vi.nodes[ep].vec)}
+		heap.Push(cands, c)
+		if accept == nil || accept(ep) {
+			heap.Push(res, c)
+		}
+	}
+	for cands.Len() > 0 {
+		c := heap.Pop(cands).(hnswCand)
+		if res.Len() >= ef && c.d > res.c[0].d {
+			break
+		}
+		for _, nb := range vi.nodes[c.id].links[layer] {
+			if vi.visited[nb] == gen {
+				continue
+			}
+			vi.visited[nb] = gen
+			d := vi.dist(q, vi.nodes[nb].vec)
+			if res.Len() >= ef && d >= res.c[0].d {
+				continue
+			}
+			heap.Push(cands, hnswCand{id: nb, d: d})
+			if accept == nil || accept(nb) {
+				heap.Push(res, hnswCand{id: nb, d: d})
+				if res.Len() > ef {
+					heap.Pop(res)
+				}
+			}
+		}
+	}
+	sort.Slice(res.c, func(i, j int) bool { return res.c[i].d < res.c[j].d })
+	return res.c
+}
+
+// selectNeighbors selects up to m of sorted candidates, that are closer to the node than to each other,
+// so links go in different directions, then adds the rest of closest candidates
+func (vi *VectorIndex) selectNeighbors(cands []hnswCand, m int) []int32 {
+	res := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range cands {
+		if len(res) >= m {
+			break
+		}
+		good := true
+		for _, r := range res {
+			if vi.dist(vi.nodes[c.id].vec, vi.nodes[r].vec) < c.d {
+				good = false
+				break
+			}
+		}
+		if good {
+			res = append(res, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+	for _, id := range skipped {
+		if len(res) >= m {
+			break
+		}
+		res = append(res, id)
+	}
+	return res
+}
+
+func (vi *VectorIndex) maxLinks(layer int) int {
+	if layer == 0 {
+		return 2 * vi.opts.M
+	}
+	return vi.opts.M
+}
+
+func (vi *VectorIndex) insert(num uint32, v Vector) {
+	id := int32(len(vi.nodes))
+	level := int(math.Floor(-math.Log(1-vi.rnd.Float64()) * vi.mult))
+	vi.nodes = append(vi.nodes, hnswNode{vec: v, num: num, links: make([][]int32, level+1)})
+	vi.byNum[num] = id
+	if vi.entry < 0 {
+		vi.entry, vi.maxLevel = id, level
+		return
+	}
+	eps := []int32{vi.entry}
+	for l := vi.maxLevel; l > level; l-- {
+		eps = []int32{vi.searchLayer(v, eps, 1, l, nil)[0].id}
+	}
+	for l := minInt(level, vi.maxLevel); l >= 0; l-- {
+		cands := vi.searchLayer(v, eps, vi.opts.EfConstruction, l, nil)
+		links := vi.selectNeighbors(cands, vi.opts.M)
+		vi.nodes[id].links[l] = links
+		for _, nb := range links {
+			nbLinks := append(vi.nodes[nb].links[l], id)
+			if len(nbLinks) > vi.maxLinks(l) {
+				nbLinks = vi.shrink(nb, nbLinks, vi.maxLinks(l))
+			}
+			vi.nodes[nb].links[l] = nbLinks
+		}
+		eps = eps[:0]
+		for _, c := range cands {
+			eps = append(eps, c.id)
+		}
+	}
+	if level > vi.maxLevel {
+		vi.entry, vi.maxLevel = id, level
+	}
+}
+
+func (vi *VectorIndex) shrink(id int32, links []int32, m int) []int32 {
+	cands := make([]hnswCand, len(links))
+	for i, nb := range links {
+		cands[i] = hnswCand{id: nb, d: vi.dist(vi.nodes[id].vec, vi.nodes[nb].vec)}
+	}
+	sort.Slice(cands, func(i, j int) bool { return cands[i].d < cands[j].d })
+	return vi.selectNeighbors(cands, m)
+}
+
+// Search returns up to k rows with vectors nearest to q ordered by distance,
+// filter limits rows to its ids, nil filter means all rows. Search is exact for small tables and filters.
+func (vi *VectorIndex) Search(q Vector, k int, filter IDIterator) ([]VectorHit, error) {
+	return vi.search(q, k, filter, false)
+}
+
+// SearchExact is brute-force Search
+func (vi *VectorIndex) SearchExact(q Vector, k int, filter IDIterator) ([]VectorHit, error) {
+	return vi.search(q, k, filter, true)
+}
+
+// SearchIDs is like Search, but returns iterator over ids in ascending order
+func (vi *VectorIndex) SearchIDs(q Vector, k int, filter IDIterator) (IDIterator, error) {
+	hits, err := vi.Search(q, k, filter)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bitmap{}
+	for _, hit := range hits {
+		if num, ok := vi.mt.RowNumber(hit.ID); ok {
+			b.Add(num)
+		}
+	}
+	return vi.mt.BitmapIDs(b), nil
+}
+
+func (vi *VectorIndex) search(q Vector, k int, filter IDIterator, exact bool) ([]VectorHit, error) {
+	if k <= 0 || len(vi.byNum) == 0 {
+		return nil, nil
+	}
+	if len(q) != vi.dim {
+		return nil, fmt.Errorf("%w: query vector dimension %d is not %d", ErrConstraint, len(q), vi.dim)
+	}
+	q = vi.prepare(q)
+	var allow *Bitmap
+	if filter != nil {
+		allow = vi.mt.BitmapOf(filter)
+	}
+	var cands []hnswCand
+	if exact || len(vi.byNum) <= vi.opts.ExactThreshold || (allow != nil && allow.Cardinality() <= vi.opts.ExactThreshold) {
+		cands = vi.bruteForce(q, k, allow)
+	} else {
+		cands = vi.searchGraph(q, k, allow)
+	}
+	res := make([]VectorHit, 0, len(cands))
+	for _, c := range cands {
+		if id, ok := vi.mt.RowID(vi.nodes[c.id].num); ok {
+			res = append(res, VectorHit{ID: id, Distance: vi.metricDist(c.d)})
+		}
+	}
+	return res, nil
+}
+
+func (vi *VectorIndex) bruteForce(q Vector, k int, allow *Bitmap) []hnswCand {
+	res := &hnswHeap{max: true}
+	add := func(id int32) {
+		d := vi.dist(q, vi.nodes[id].vec)
+		if res.Len() < k {
+			heap.Push(res, hnswCand{id: id, d: d})
+		} else if d < res.c[0].d {
+			res.c[0] = hnswCand{id: id, d: d}
+			heap.Fix(res, 0)
+		}
+	}
+	if allow != nil {
+		allow.Iterate(func(num uint32) bool {
+			if id, ok := vi.byNum[num]; ok {
+				add(id)
+			}
+			return true
+		})
+	} else {
+		for _, id := range vi.byNum {
+			add(id)
+		}
+	}
+	sort.Slice(res.c, func(i, j int) bool { return res.c[i].d < res.c[j].d })
+	return res.c
+}
+
+func (vi *VectorIndex) searchGraph(q Vector, k int, allow *Bitmap) []hnswCand {
+	eps := []int32{vi.entry}
+	for l := vi.maxLevel; l > 0; l-- {
+		eps = []int32{vi.searchLayer(q, eps, 1, l, nil)[0].id}
+	}
+	ef := vi.opts.EfSearch
+	if ef < k {
+		ef = k
+	}
+	res := vi.searchLayer(q, eps, ef, 0, func(id int32) bool {
+		n := &vi.nodes[id]
+		return !n.deleted && (allow == nil || allow.Contains(n.num))
+	})
+	if len(res) > k {
+		res = res[:k]
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package inmemdb
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type TestDocMO struct {
+	ID        UUIDv4
+	Lang      string
+	Embedding Vector
+}
+
+func (t TestDocMO) StoreName() string { return "testdocmo" }
+
+func TestVector(t *testing.T) {
+	var v Vector
+	if err := v.Scan("[1, 2.5,-3]"); err != nil || len(v) != 3 || v[1] != 2.5 || v[2] != -3 {
+		t.Fatalf("wrong scan: %v %v", v, err)
+	}
+	if s, _ := v.Value(); s != "[1,2.5,-3]" {
+		t.Errorf("wrong value: %v", s)
+	}
+	if cv, err := ConvertToType([]interface{}{1.0, 2.0}, vectorType); err != nil || len(cv.(Vector)) != 2 {
+		t.Errorf("wrong conversion: %v %v", cv, err)
+	}
+	a, b := Vector{1, 0}, Vector{0, 2}
+	if d := MetricCosine.Distance(a, b); d != 1 {
+		t.Errorf("wrong cosine distance %v", d)
+	}
+	if d := MetricL2.Distance(a, b); math.Abs(d-math.Sqrt(5)) > 1e-6 {
+		t.Errorf("wrong l2 distance %v", d)
+	}
+	if d := MetricDot.Distance(Vector{1, 2}, Vector{3, 4}); d != -11 {
+		t.Errorf("wrong dot distance %v", d)
+	}
+}
+
+func TestVectorIndex(t *testing.T) {
+	const dim, k = 16, 10
+	rnd := rand.New(rand.NewSource(1))
+	randVector := func() Vector {
+		v := make(Vector, dim)
+		for i := range v {
+			v[i] = float32(rnd.NormFloat64())
+		}
+		return v
+	}
+	for _, metric := range []VectorMetric{MetricCosine, MetricL2, MetricDot} {
+		t.Run(metric.String(), func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, tbl *Table[TestDocMO]) {
+				mt := tbl.ModelTable()
+				emb := MustField[TestDocMO, Vector](tbl, "Embedding")
+				lang := MustField[TestDocMO, string](tbl, "Lang")
+				if _, err := mt.CreateVectorIndex(lang.FD(), VectorIndexOptions{}); !errors.Is(err, ErrNotVector) {
+					t.Fatalf("expected ErrNotVector, got %v", err)
+				}
+				if err := emb.CreateVectorIndex(VectorIndexOptions{Metric: metric, EfConstruction: 64, ExactThreshold: 50}); err != nil {
+					t.Fatal(err)
+				}
+				if err := lang.CreateHashIndex(); err != nil {
+					t.Fatal(err)
+				}
+				vi := mt.VectorIndex(emb.FD())
+
+				var ids []UUIDv4
+				for i := 0; i < 1000; i++ {
+					doc := TestDocMO{ID: NewV4(), Lang: []string{"en", "ru", "de"}[i%3], Embedding: randVector()}
+					if err := tbl.Insert(doc); err != nil {
+						t.Fatal(err)
+					}
+					ids = append(ids, doc.ID)
+				}
+				// tombstones and rebuild
+				for _, id := range ids[:600] {
+					if err := tbl.Delete(id); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for _, id := range ids[600:700] {
+					if err := tbl.Insert(TestDocMO{ID: id, Lang: "en", Embedding: randVector()}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if vi.Len() != 400 {
+					t.Fatalf("expected 400 vectors, got %d", vi.Len())
+				}
+				if err := tbl.Insert(TestDocMO{ID: NewV4(), Embedding: Vector{1, 2}}); !errors.Is(err, ErrConstraint) {
+					t.Fatalf("expected ErrConstraint, got %v", err)
+				}
+
+				found, total := 0, 0
+				for i := 0; i < 20; i++ {
+					q := randVector()
+					exact, err := vi.SearchExact(q, k, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					approx, err := vi.Search(q, k, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(exact) != k || len(approx) != k {
+						t.Fatalf("expected %d hits, got %d and %d", k, len(exact), len(approx))
+					}
+					for j := 1; j < k; j++ {
+						if approx[j].Distance < approx[j-1].Distance {
+							t.Fatal("hits are not ordered by distance")
+						}
+					}
+					for _, e := range exact {
+						for _, a := range approx {
+							if a.ID.ModelEqual(e.ID) {
+								found++
+								break
+							}
+						}
+					}
+					total += k
+				}
+				if recall := float64(found) / float64(total); recall < 0.9 {
+					t.Errorf("%v: low recall %v", metric, recall)
+				}
+
+				// filtered search returns only allowed rows
+				q := randVector()
+				de, _ := lang.Eq("de")
+				hits, err := vi.Search(q, k, de)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(hits) != k {
+					t.Fatalf("expected %d filtered hits, got %d", k, len(hits))
+				}
+				for _, hit := range hits {
+					if doc, _, _ := tbl.Get(hit.ID); doc.Lang != "de" {
+						t.Fatalf("filtered hit has lang %q", doc.Lang)
+					}
+				}
+				ru, _ := lang.Eq("ru")
+				nru := ru.Cardinality()
+				ru, _ = lang.Eq("ru")
+				it, err := vi.SearchIDs(q, 1000, ru)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n := len(collectIDs(it)); n != nru {
+					t.Errorf("expected all %d ru rows, got %d", nru, n)
+				}
+			})
+		})
+	}
+}